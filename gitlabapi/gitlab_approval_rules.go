@@ -0,0 +1,272 @@
+package gitlabapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"sort"
+
+	"github.com/xanzy/go-gitlab"
+)
+
+// ApprovalSettings are the desired merge request approval settings of a project.
+// Fields that are left out of the config file are not managed.
+type ApprovalSettings struct {
+	ResetApprovalsOnPush                      *bool `json:"reset_approvals_on_push"`
+	MergeRequestsAuthorApproval               *bool `json:"merge_requests_author_approval"`
+	MergeRequestsDisableCommittersApproval    *bool `json:"merge_requests_disable_committers_approval"`
+	DisableOverridingApproversPerMergeRequest *bool `json:"disable_overriding_approvers_per_merge_request"`
+	RequirePasswordToApprove                  *bool `json:"require_password_to_approve"`
+}
+
+// ApprovalRuleSpec is a project-level approval rule, identified by its name.
+type ApprovalRuleSpec struct {
+	Name              string   `json:"name"`
+	ApprovalsRequired int      `json:"approvals_required"`
+	UserIDs           []int    `json:"user_ids"`
+	GroupIDs          []int    `json:"group_ids"`
+	ProtectedBranches []string `json:"protected_branches"` // Names of protected branches; "default" is the default branch; empty for all branches
+	Delete            bool     `json:"delete"`             // Delete the rule instead of creating or updating it
+}
+
+// ApprovalConfig is the approval config file.
+type ApprovalConfig struct {
+	Settings ApprovalSettings   `json:"settings"`
+	Rules    []ApprovalRuleSpec `json:"rules"`
+}
+
+// LoadApprovalConfig reads the approval settings and rules from a JSON file.
+func LoadApprovalConfig(path string) (*ApprovalConfig, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	config := &ApprovalConfig{}
+	if err := json.Unmarshal(content, config); err != nil {
+		return nil, fmt.Errorf("failed to parse approval config %s: %v", path, err)
+	}
+	for _, rule := range config.Rules {
+		if rule.Name == "" {
+			return nil, fmt.Errorf("approval config %s contains a rule without name", path)
+		}
+	}
+	return config, nil
+}
+
+// ReconcileApprovals compares the approval settings and rules of the project with the
+// config, reports the drift and applies it. In dry-run mode nothing is changed.
+func ReconcileApprovals(client *gitlab.Client, project *gitlab.Project, config *ApprovalConfig, dryRun bool) error {
+	current, _, err := client.Projects.GetApprovalConfiguration(project.ID)
+	if err != nil {
+		return fmt.Errorf("failed to get approval settings: %v", err)
+	}
+
+	byName := map[string]*gitlab.ProjectApprovalRule{}
+	err = Paginate(client, project.ID, func(options gitlab.ListOptions) (*gitlab.Response, error) {
+		rules, resp, err := client.Projects.GetProjectApprovalRules(project.ID, (*gitlab.GetProjectApprovalRulesListsOptions)(&options))
+		if err != nil {
+			return nil, err
+		}
+		for _, rule := range rules {
+			byName[rule.Name] = rule
+		}
+		return resp, nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list approval rules: %v", err)
+	}
+
+	branchIDs, err := protectedBranchIDs(client, project.ID)
+	if err != nil {
+		return err
+	}
+
+	drifts := ApprovalSettingsDrift(current, config.Settings)
+	var changes []func() error
+	if len(drifts) > 0 {
+		changes = append(changes, func() error {
+			_, _, err := client.Projects.ChangeApprovalConfiguration(project.ID, approvalOptionsForDrift(current, config.Settings))
+			if err != nil {
+				return fmt.Errorf("failed to update approval settings: %v", err)
+			}
+			return nil
+		})
+	}
+
+	for _, spec := range config.Rules {
+		spec := spec
+		existing := byName[spec.Name]
+
+		if spec.Delete {
+			if existing != nil {
+				drifts = append(drifts, FieldDrift{Field: "rule " + spec.Name, Current: "present", Desired: "deleted"})
+				ruleID := existing.ID
+				changes = append(changes, func() error {
+					_, err := client.Projects.DeleteProjectApprovalRule(project.ID, ruleID)
+					if err != nil {
+						return fmt.Errorf("failed to delete approval rule %s: %v", spec.Name, err)
+					}
+					return nil
+				})
+			}
+			continue
+		}
+
+		scope, err := ruleBranchIDs(project, spec, branchIDs)
+		if err != nil {
+			return err
+		}
+
+		if existing == nil {
+			drifts = append(drifts, FieldDrift{Field: "rule " + spec.Name, Current: "missing", Desired: "present"})
+			changes = append(changes, func() error {
+				_, _, err := client.Projects.CreateProjectApprovalRule(project.ID, &gitlab.CreateProjectLevelRuleOptions{
+					Name:               gitlab.String(spec.Name),
+					ApprovalsRequired:  gitlab.Int(spec.ApprovalsRequired),
+					UserIDs:            &spec.UserIDs,
+					GroupIDs:           &spec.GroupIDs,
+					ProtectedBranchIDs: &scope,
+				})
+				if err != nil {
+					return fmt.Errorf("failed to create approval rule %s: %v", spec.Name, err)
+				}
+				return nil
+			})
+			continue
+		}
+
+		ruleDrifts := ApprovalRuleDrift(existing, spec, scope)
+		if len(ruleDrifts) == 0 {
+			continue
+		}
+		drifts = append(drifts, ruleDrifts...)
+		ruleID := existing.ID
+		changes = append(changes, func() error {
+			_, _, err := client.Projects.UpdateProjectApprovalRule(project.ID, ruleID, &gitlab.UpdateProjectLevelRuleOptions{
+				Name:               gitlab.String(spec.Name),
+				ApprovalsRequired:  gitlab.Int(spec.ApprovalsRequired),
+				UserIDs:            &spec.UserIDs,
+				GroupIDs:           &spec.GroupIDs,
+				ProtectedBranchIDs: &scope,
+			})
+			if err != nil {
+				return fmt.Errorf("failed to update approval rule %s: %v", spec.Name, err)
+			}
+			return nil
+		})
+	}
+
+	printDrift(project.PathWithNamespace, drifts)
+	if dryRun {
+		return nil
+	}
+
+	for _, change := range changes {
+		if err := change(); err != nil {
+			return err
+		}
+	}
+	if len(changes) > 0 {
+		log.Printf("Applied %d approval change(s) to project %s", len(changes), project.PathWithNamespace)
+	}
+	return nil
+}
+
+// ApprovalSettingsDrift returns the managed approval settings whose current value differs.
+func ApprovalSettingsDrift(current *gitlab.ProjectApprovals, settings ApprovalSettings) []FieldDrift {
+	var drifts driftList
+	compare(&drifts, "reset_approvals_on_push", current.ResetApprovalsOnPush, settings.ResetApprovalsOnPush)
+	compare(&drifts, "merge_requests_author_approval", current.MergeRequestsAuthorApproval, settings.MergeRequestsAuthorApproval)
+	compare(&drifts, "merge_requests_disable_committers_approval", current.MergeRequestsDisableCommittersApproval, settings.MergeRequestsDisableCommittersApproval)
+	compare(&drifts, "disable_overriding_approvers_per_merge_request", current.DisableOverridingApproversPerMergeRequest, settings.DisableOverridingApproversPerMergeRequest)
+	compare(&drifts, "require_password_to_approve", current.RequirePasswordToApprove, settings.RequirePasswordToApprove)
+	return drifts
+}
+
+// ApprovalRuleDrift returns the fields of the rule that differ from the spec. scope is
+// the resolved list of protected branch IDs of the spec.
+func ApprovalRuleDrift(rule *gitlab.ProjectApprovalRule, spec ApprovalRuleSpec, scope []int) []FieldDrift {
+	var users, groups, branches []int
+	for _, user := range rule.Users {
+		users = append(users, user.ID)
+	}
+	for _, group := range rule.Groups {
+		groups = append(groups, group.ID)
+	}
+	for _, branch := range rule.ProtectedBranches {
+		branches = append(branches, branch.ID)
+	}
+
+	var drifts driftList
+	field := func(name string) string {
+		return "rule " + spec.Name + " " + name
+	}
+	compare(&drifts, field("approvals_required"), rule.ApprovalsRequired, &spec.ApprovalsRequired)
+	if !equalIDs(users, spec.UserIDs) {
+		drifts = append(drifts, FieldDrift{Field: field("user_ids"), Current: formatIDs(users), Desired: formatIDs(spec.UserIDs)})
+	}
+	if !equalIDs(groups, spec.GroupIDs) {
+		drifts = append(drifts, FieldDrift{Field: field("group_ids"), Current: formatIDs(groups), Desired: formatIDs(spec.GroupIDs)})
+	}
+	if !equalIDs(branches, scope) {
+		drifts = append(drifts, FieldDrift{Field: field("protected_branch_ids"), Current: formatIDs(branches), Desired: formatIDs(scope)})
+	}
+	return drifts
+}
+
+// approvalOptionsForDrift builds change options that only contain the differing settings.
+func approvalOptionsForDrift(current *gitlab.ProjectApprovals, settings ApprovalSettings) *gitlab.ChangeApprovalConfigurationOptions {
+	return &gitlab.ChangeApprovalConfigurationOptions{
+		ResetApprovalsOnPush:                      changed(current.ResetApprovalsOnPush, settings.ResetApprovalsOnPush),
+		MergeRequestsAuthorApproval:               changed(current.MergeRequestsAuthorApproval, settings.MergeRequestsAuthorApproval),
+		MergeRequestsDisableCommittersApproval:    changed(current.MergeRequestsDisableCommittersApproval, settings.MergeRequestsDisableCommittersApproval),
+		DisableOverridingApproversPerMergeRequest: changed(current.DisableOverridingApproversPerMergeRequest, settings.DisableOverridingApproversPerMergeRequest),
+		RequirePasswordToApprove:                  changed(current.RequirePasswordToApprove, settings.RequirePasswordToApprove),
+	}
+}
+
+// ruleBranchIDs resolves the protected branch names of the rule to their IDs.
+func ruleBranchIDs(project *gitlab.Project, spec ApprovalRuleSpec, branchIDs map[string]int) ([]int, error) {
+	ids := []int{}
+	for _, name := range spec.ProtectedBranches {
+		if name == "default" {
+			name = project.DefaultBranch
+		}
+		id, ok := branchIDs[name]
+		if !ok {
+			return nil, fmt.Errorf("approval rule %s: branch %s is not protected in project %s", spec.Name, name, project.PathWithNamespace)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// protectedBranchIDs maps the names of the protected branches of the project to their IDs.
+func protectedBranchIDs(client *gitlab.Client, projectID int) (map[string]int, error) {
+	ids := map[string]int{}
+	err := Paginate(client, projectID, func(options gitlab.ListOptions) (*gitlab.Response, error) {
+		branches, resp, err := client.ProtectedBranches.ListProtectedBranches(projectID, &gitlab.ListProtectedBranchesOptions{
+			ListOptions: options,
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, branch := range branches {
+			ids[branch.Name] = branch.ID
+		}
+		return resp, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list protected branches: %v", err)
+	}
+	return ids, nil
+}
+
+// formatIDs formats IDs sorted, for drift output.
+func formatIDs(ids []int) string {
+	sorted := append([]int{}, ids...)
+	sort.Ints(sorted)
+	return fmt.Sprint(sorted)
+}
@@ -0,0 +1,106 @@
+package gitlabapi
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/xanzy/go-gitlab"
+)
+
+const (
+	maxSnippetLength = 120 // Longest snippet shown for an audit match
+)
+
+// AuditOptions describes what the audit looks for. Files matching Matcher are reported
+// as a whole when Content is nil; otherwise every line matching Content is reported.
+type AuditOptions struct {
+	Ref       string // Defaults to the project's default branch
+	Matcher   *PathMatcher
+	Content   *regexp.Regexp
+	UseSearch bool   // Use the search API to find candidate files instead of reading every file
+	Query     string // Literal text passed to the search API
+}
+
+// NewAuditReport returns an empty report with the audit columns.
+func NewAuditReport() *Report {
+	return &Report{Headers: []string{"Project", "Path", "Line", "Snippet"}}
+}
+
+// Audit searches the project without modifying anything and adds every match to the report.
+func Audit(client *gitlab.Client, project *gitlab.Project, options AuditOptions, report *Report) error {
+	ref := options.Ref
+	if ref == "" {
+		ref = project.DefaultBranch
+	}
+
+	if options.UseSearch && options.Content != nil {
+		return auditWithSearch(client, project, ref, options, report)
+	}
+
+	files, err := listRepositoryFiles(client, project.ID, ref)
+	if err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		if !options.Matcher.Match(file.Path) {
+			continue
+		}
+
+		if options.Content == nil {
+			report.AddRow(project.PathWithNamespace, file.Path, "", "")
+			continue
+		}
+
+		content, _, err := getFileContent(client, project.ID, file.Path, ref)
+		if err != nil {
+			log.Printf("Failed to read file %s for project %s: %v\n", file.Path, project.Name, err)
+			continue
+		}
+		auditLines(project, file.Path, string(content), 1, options.Content, report)
+	}
+	return nil
+}
+
+// auditWithSearch uses the search API to find candidate blobs and then applies the
+// content regex to the returned chunks.
+func auditWithSearch(client *gitlab.Client, project *gitlab.Project, ref string, options AuditOptions, report *Report) error {
+	query := options.Query
+	if query == "" {
+		query = options.Content.String()
+	}
+
+	return Paginate(client, project.ID, func(listOptions gitlab.ListOptions) (*gitlab.Response, error) {
+		blobs, resp, err := client.Search.BlobsByProject(project.ID, query, &gitlab.SearchOptions{
+			ListOptions: listOptions,
+			Ref:         gitlab.String(ref),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to search project %s: %v", project.Name, err)
+		}
+		for _, blob := range blobs {
+			if options.Matcher.Match(blob.Path) {
+				auditLines(project, blob.Path, blob.Data, blob.Startline, options.Content, report)
+			}
+		}
+		return resp, nil
+	})
+}
+
+// auditLines reports every line of content matching re. firstLine is the line number
+// of the first line of content in the file.
+func auditLines(project *gitlab.Project, filePath, content string, firstLine int, re *regexp.Regexp, report *Report) {
+	for i, line := range strings.Split(content, "\n") {
+		if !re.MatchString(line) {
+			continue
+		}
+		snippet := strings.TrimSpace(line)
+		if runes := []rune(snippet); len(runes) > maxSnippetLength {
+			snippet = string(runes[:maxSnippetLength]) + "..."
+		}
+		report.AddRow(project.PathWithNamespace, filePath, strconv.Itoa(firstLine+i), snippet)
+	}
+}
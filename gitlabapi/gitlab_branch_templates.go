@@ -0,0 +1,198 @@
+package gitlabapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/xanzy/go-gitlab"
+)
+
+// Kinds of reference a new branch can be created from.
+const (
+	RefBranch  = "branch"  // A fixed branch name
+	RefDefault = "default" // The project's default branch
+	RefTag     = "tag"     // The latest tag matching a pattern
+	RefSHA     = "sha"     // A commit SHA looked up in a mapping file
+)
+
+// RefSpec describes how the reference of a new branch is chosen for each project.
+type RefSpec struct {
+	Kind    string
+	Value   string            // Branch name or tag pattern
+	Mapping map[string]string // Project path with namespace to SHA, used with RefSHA
+}
+
+// BranchNameData is the data available to branch name templates.
+type BranchNameData struct {
+	Name      string // Project path, e.g. "my-service"
+	Path      string // Project path with namespace, e.g. "group/my-service"
+	Namespace string // Namespace full path, e.g. "group"
+	Date      string // Current date as YYYY-MM-DD
+	Version   string // Version read from the version file, if any
+}
+
+// ParseRefSpec parses a reference specification. It accepts "default", "tag:<pattern>",
+// "sha:<mapping file>" or a plain branch name.
+func ParseRefSpec(spec string) (RefSpec, error) {
+	switch {
+	case spec == RefDefault:
+		return RefSpec{Kind: RefDefault}, nil
+	case strings.HasPrefix(spec, RefTag+":"):
+		pattern := strings.TrimPrefix(spec, RefTag+":")
+		if _, err := path.Match(pattern, ""); err != nil {
+			return RefSpec{}, fmt.Errorf("invalid tag pattern %s: %v", pattern, err)
+		}
+		return RefSpec{Kind: RefTag, Value: pattern}, nil
+	case strings.HasPrefix(spec, RefSHA+":"):
+		mappingPath := strings.TrimPrefix(spec, RefSHA+":")
+		content, err := ioutil.ReadFile(mappingPath)
+		if err != nil {
+			return RefSpec{}, err
+		}
+		mapping := map[string]string{}
+		if err := json.Unmarshal(content, &mapping); err != nil {
+			return RefSpec{}, fmt.Errorf("failed to parse SHA mapping %s: %v", mappingPath, err)
+		}
+		return RefSpec{Kind: RefSHA, Mapping: mapping}, nil
+	case spec == "":
+		return RefSpec{}, fmt.Errorf("reference must not be empty")
+	}
+	return RefSpec{Kind: RefBranch, Value: spec}, nil
+}
+
+// ResolveRef returns the branch, tag or SHA the spec points to for the project.
+func ResolveRef(client *gitlab.Client, project *gitlab.Project, spec RefSpec) (string, error) {
+	switch spec.Kind {
+	case RefDefault:
+		if project.DefaultBranch == "" {
+			return "", fmt.Errorf("project %s has no default branch", project.PathWithNamespace)
+		}
+		return project.DefaultBranch, nil
+	case RefTag:
+		return latestTag(client, project.ID, spec.Value)
+	case RefSHA:
+		sha, ok := spec.Mapping[project.PathWithNamespace]
+		if !ok {
+			return "", fmt.Errorf("no SHA mapped for project %s", project.PathWithNamespace)
+		}
+		return sha, nil
+	}
+	return spec.Value, nil
+}
+
+// latestTag returns the most recently updated tag whose name matches the pattern.
+func latestTag(client *gitlab.Client, projectID int, pattern string) (string, error) {
+	found, err := findLatestTag(client, projectID, pattern)
+	if err != nil {
+		return "", err
+	}
+	if found == "" {
+		return "", fmt.Errorf("no tag matches pattern %s", pattern)
+	}
+	return found, nil
+}
+
+// findLatestTag returns the most recently updated tag whose name matches the pattern,
+// or an empty string if there is none.
+func findLatestTag(client *gitlab.Client, projectID int, pattern string) (string, error) {
+	var found string
+	err := Paginate(client, projectID, func(options gitlab.ListOptions) (*gitlab.Response, error) {
+		tags, resp, err := client.Tags.ListTags(projectID, &gitlab.ListTagsOptions{
+			ListOptions: options,
+			OrderBy:     gitlab.String("updated"),
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, tag := range tags {
+			if ok, _ := path.Match(pattern, tag.Name); ok {
+				found = tag.Name
+				// Stop paginating once the newest match is found
+				resp.NextPage = 0
+				break
+			}
+		}
+		return resp, nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to list tags: %v", err)
+	}
+	return found, nil
+}
+
+// RenderBranchName renders the branch name template for the project. If versionFile is
+// set, the version is read from that file at ref; package.json files are parsed for
+// their "version" field and any other file is used as-is.
+func RenderBranchName(client *gitlab.Client, project *gitlab.Project, ref, nameTemplate, versionFile string) (string, error) {
+	return renderRefName(client, project, ref, "branch", nameTemplate, versionFile)
+}
+
+// RenderTagName renders the tag name template for the project, with the same data and
+// version handling as RenderBranchName.
+func RenderTagName(client *gitlab.Client, project *gitlab.Project, ref, nameTemplate, versionFile string) (string, error) {
+	return renderRefName(client, project, ref, "tag", nameTemplate, versionFile)
+}
+
+// renderRefName renders a branch or tag name template; kind is used in errors.
+func renderRefName(client *gitlab.Client, project *gitlab.Project, ref, kind, nameTemplate, versionFile string) (string, error) {
+	tmpl, err := template.New(kind).Option("missingkey=error").Parse(nameTemplate)
+	if err != nil {
+		return "", fmt.Errorf("invalid %s name template: %v", kind, err)
+	}
+
+	data := BranchNameData{
+		Name: project.Path,
+		Path: project.PathWithNamespace,
+		Date: time.Now().Format("2006-01-02"),
+	}
+	if project.Namespace != nil {
+		data.Namespace = project.Namespace.FullPath
+	}
+
+	if versionFile != "" {
+		data.Version, err = readVersion(client, project.ID, ref, versionFile)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	var sb strings.Builder
+	if err := tmpl.Execute(&sb, data); err != nil {
+		return "", fmt.Errorf("failed to render %s name: %v", kind, err)
+	}
+	return strings.TrimSpace(sb.String()), nil
+}
+
+// readVersion reads the version from the given file in the repository at ref.
+func readVersion(client *gitlab.Client, projectID int, ref, versionFile string) (string, error) {
+	content, _, err := client.RepositoryFiles.GetRawFile(projectID, versionFile, &gitlab.GetRawFileOptions{
+		Ref: gitlab.String(ref),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to read version file %s: %v", versionFile, err)
+	}
+
+	if path.Base(versionFile) == "package.json" {
+		var pkg struct {
+			Version string `json:"version"`
+		}
+		if err := json.Unmarshal(content, &pkg); err != nil {
+			return "", fmt.Errorf("failed to parse %s: %v", versionFile, err)
+		}
+		if pkg.Version == "" {
+			return "", fmt.Errorf("no version found in %s", versionFile)
+		}
+		return pkg.Version, nil
+	}
+
+	version := strings.TrimSpace(string(content))
+	if version == "" {
+		return "", fmt.Errorf("version file %s is empty", versionFile)
+	}
+	return version, nil
+}
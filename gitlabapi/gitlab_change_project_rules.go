@@ -1,17 +1,65 @@
 package gitlabapi
 
 import (
+	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"log"
+	"net/http"
 
 	"github.com/xanzy/go-gitlab"
 )
 
-// ChangeProjectRules changes the push rules for the specified project.
-func ChangeProjectRules(client *gitlab.Client, projectID int, projectName, newRegex string) error {
-	_, _, err := client.Projects.EditProjectPushRule(projectID, &gitlab.EditProjectPushRuleOptions{
-		BranchNameRegex: &newRegex,
-	})
+// LoadPushRules reads the desired push rules from a JSON file using the GitLab API
+// field names, e.g. "commit_message_regex" or "prevent_secrets". Fields that are left
+// out are not managed.
+func LoadPushRules(path string) (*gitlab.EditProjectPushRuleOptions, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	rules := &gitlab.EditProjectPushRuleOptions{}
+	if err := json.Unmarshal(content, rules); err != nil {
+		return nil, fmt.Errorf("failed to parse push rules %s: %v", path, err)
+	}
+	return rules, nil
+}
+
+// ChangeProjectRules changes the push rules for the specified project. The fields that
+// differ are printed before they are applied; in dry-run mode nothing is changed.
+// Push rules are created when the project has none.
+func ChangeProjectRules(client *gitlab.Client, projectID int, projectName string, rules *gitlab.EditProjectPushRuleOptions, dryRun bool) error {
+	current, err := getPushRules(client, projectID)
+	if err != nil {
+		return fmt.Errorf("failed to get push rules for project %s: %v", projectName, err)
+	}
+
+	if current == nil {
+		fmt.Printf("Project %s has no push rules, creating:\n", projectName)
+		for _, drift := range PushRuleDrift(&gitlab.ProjectPushRules{}, rules) {
+			fmt.Printf("  %s\n", drift)
+		}
+		if dryRun {
+			return nil
+		}
+
+		addOptions := gitlab.AddProjectPushRuleOptions(*rules)
+		_, _, err = client.Projects.AddProjectPushRule(projectID, &addOptions)
+		if err != nil {
+			return fmt.Errorf("failed to add push rule for project %s: %v", projectName, err)
+		}
+		log.Printf("Created push rule for project %s", projectName)
+		return nil
+	}
+
+	drifts := PushRuleDrift(current, rules)
+	printDrift(projectName, drifts)
+	if len(drifts) == 0 || dryRun {
+		return nil
+	}
+
+	_, _, err = client.Projects.EditProjectPushRule(projectID, rules)
 	if err != nil {
 		return fmt.Errorf("failed to update push rule for project %s: %v", projectName, err)
 	}
@@ -19,3 +67,36 @@ func ChangeProjectRules(client *gitlab.Client, projectID int, projectName, newRe
 	log.Printf("Updated push rule for project %s", projectName)
 	return nil
 }
+
+// getPushRules returns the push rules of the project, or nil if it has none.
+func getPushRules(client *gitlab.Client, projectID int) (*gitlab.ProjectPushRules, error) {
+	rules, resp, err := client.Projects.GetProjectPushRules(projectID)
+	if err != nil {
+		if resp != nil && resp.StatusCode == http.StatusNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if rules == nil || rules.ID == 0 {
+		return nil, nil
+	}
+	return rules, nil
+}
+
+// PushRuleDrift returns the managed push rule fields whose current value differs.
+func PushRuleDrift(current *gitlab.ProjectPushRules, desired *gitlab.EditProjectPushRuleOptions) []FieldDrift {
+	var drifts driftList
+	compare(&drifts, "author_email_regex", current.AuthorEmailRegex, desired.AuthorEmailRegex)
+	compare(&drifts, "branch_name_regex", current.BranchNameRegex, desired.BranchNameRegex)
+	compare(&drifts, "commit_committer_check", current.CommitCommitterCheck, desired.CommitCommitterCheck)
+	compare(&drifts, "commit_committer_name_check", current.CommitCommitterNameCheck, desired.CommitCommitterNameCheck)
+	compare(&drifts, "commit_message_negative_regex", current.CommitMessageNegativeRegex, desired.CommitMessageNegativeRegex)
+	compare(&drifts, "commit_message_regex", current.CommitMessageRegex, desired.CommitMessageRegex)
+	compare(&drifts, "deny_delete_tag", current.DenyDeleteTag, desired.DenyDeleteTag)
+	compare(&drifts, "file_name_regex", current.FileNameRegex, desired.FileNameRegex)
+	compare(&drifts, "max_file_size", current.MaxFileSize, desired.MaxFileSize)
+	compare(&drifts, "member_check", current.MemberCheck, desired.MemberCheck)
+	compare(&drifts, "prevent_secrets", current.PreventSecrets, desired.PreventSecrets)
+	compare(&drifts, "reject_unsigned_commits", current.RejectUnsignedCommits, desired.RejectUnsignedCommits)
+	return drifts
+}
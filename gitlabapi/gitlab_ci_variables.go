@@ -0,0 +1,360 @@
+package gitlabapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/go-retryablehttp"
+	"github.com/xanzy/go-gitlab"
+)
+
+// Operations on CI/CD variables.
+const (
+	VariablesList   = "list"   // Report the existing variables
+	VariablesDiff   = "diff"   // Report the differences with the variables file
+	VariablesApply  = "apply"  // Create missing and update differing variables
+	VariablesDelete = "delete" // Delete the variables listed in the file
+)
+
+// hiddenValue replaces variable values in every report and log line.
+const hiddenValue = "[hidden]"
+
+// VariableSpec is a CI/CD variable in a variables file. The value is read from the
+// environment variable ValueFromEnv or from the file ValueFile, never from the file itself.
+type VariableSpec struct {
+	Key              string                   `json:"key"`
+	ValueFromEnv     string                   `json:"value_from_env"`
+	ValueFile        string                   `json:"value_file"`
+	Description      string                   `json:"description"`
+	EnvironmentScope string                   `json:"environment_scope"`
+	Protected        bool                     `json:"protected"`
+	Masked           bool                     `json:"masked"`
+	Raw              bool                     `json:"raw"`
+	VariableType     gitlab.VariableTypeValue `json:"variable_type"`
+
+	value string
+}
+
+// ciVariable is the common form of project and group variables.
+type ciVariable struct {
+	Key              string
+	Value            string
+	Description      string
+	EnvironmentScope string
+	Protected        bool
+	Masked           bool
+	Raw              bool
+	VariableType     gitlab.VariableTypeValue
+}
+
+// variableTarget lists and changes the variables of a project or a group.
+type variableTarget struct {
+	name   string
+	list   func() ([]ciVariable, error)
+	create func(v ciVariable) error
+	update func(v ciVariable) error
+	remove func(v ciVariable) error
+}
+
+// LoadVariableSpecs reads the variables file and resolves every value from the
+// environment or from its value file.
+func LoadVariableSpecs(path string, needValues bool) ([]*VariableSpec, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var specs []*VariableSpec
+	if err := json.Unmarshal(content, &specs); err != nil {
+		return nil, fmt.Errorf("failed to parse variables file %s: %v", path, err)
+	}
+
+	for _, spec := range specs {
+		if spec.Key == "" {
+			return nil, fmt.Errorf("variables file %s contains a variable without key", path)
+		}
+		if spec.EnvironmentScope == "" {
+			spec.EnvironmentScope = "*"
+		}
+		if spec.VariableType == "" {
+			spec.VariableType = gitlab.EnvVariableType
+		}
+		if !needValues {
+			continue
+		}
+
+		switch {
+		case spec.ValueFromEnv != "":
+			value, ok := os.LookupEnv(spec.ValueFromEnv)
+			if !ok {
+				return nil, fmt.Errorf("environment variable %s for %s is not set", spec.ValueFromEnv, spec.Key)
+			}
+			spec.value = value
+		case spec.ValueFile != "":
+			value, err := ioutil.ReadFile(spec.ValueFile)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read value of %s: %v", spec.Key, err)
+			}
+			spec.value = strings.TrimSuffix(string(value), "\n")
+		default:
+			return nil, fmt.Errorf("variable %s needs value_from_env or value_file", spec.Key)
+		}
+	}
+	return specs, nil
+}
+
+// NewVariablesReport returns an empty report with the variable columns. Values are
+// never part of the report.
+func NewVariablesReport() *Report {
+	return &Report{Headers: []string{"Target", "Key", "Scope", "Type", "Protected", "Masked", "Change"}}
+}
+
+// ManageProjectVariables runs the operation on the CI/CD variables of the project.
+func ManageProjectVariables(client *gitlab.Client, project *gitlab.Project, operation string, specs []*VariableSpec, dryRun bool, report *Report) error {
+	return manageVariables(projectVariableTarget(client, project), operation, specs, dryRun, report)
+}
+
+// ManageGroupVariables runs the operation on the CI/CD variables of the group.
+func ManageGroupVariables(client *gitlab.Client, groupID int, groupName, operation string, specs []*VariableSpec, dryRun bool, report *Report) error {
+	return manageVariables(groupVariableTarget(client, groupID, groupName), operation, specs, dryRun, report)
+}
+
+// manageVariables lists, diffs, applies or deletes variables of the target. In dry-run
+// mode changes are only reported.
+func manageVariables(target variableTarget, operation string, specs []*VariableSpec, dryRun bool, report *Report) error {
+	current, err := target.list()
+	if err != nil {
+		return fmt.Errorf("failed to list variables of %s: %v", target.name, err)
+	}
+
+	if operation == VariablesList {
+		for _, v := range current {
+			report.AddRow(target.name, v.Key, v.EnvironmentScope, string(v.VariableType),
+				strconv.FormatBool(v.Protected), strconv.FormatBool(v.Masked), "")
+		}
+		return nil
+	}
+
+	for _, spec := range specs {
+		existing := findVariable(current, spec.Key, spec.EnvironmentScope)
+		desired := ciVariable{
+			Key:              spec.Key,
+			Value:            spec.value,
+			Description:      spec.Description,
+			EnvironmentScope: spec.EnvironmentScope,
+			Protected:        spec.Protected,
+			Masked:           spec.Masked,
+			Raw:              spec.Raw,
+			VariableType:     spec.VariableType,
+		}
+
+		var change string
+		var apply func(v ciVariable) error
+		switch operation {
+		case VariablesDiff, VariablesApply:
+			switch {
+			case existing == nil:
+				change, apply = "create", target.create
+			default:
+				if fields := variableChanges(*existing, desired); len(fields) > 0 {
+					change, apply = "update "+strings.Join(fields, ", "), target.update
+				}
+			}
+		case VariablesDelete:
+			if existing != nil {
+				change, apply = "delete", target.remove
+			}
+		default:
+			return fmt.Errorf("unknown variables operation: %s", operation)
+		}
+
+		if change == "" {
+			continue
+		}
+		if operation == VariablesDiff || dryRun {
+			change = "would " + change
+		} else if err := apply(desired); err != nil {
+			change = fmt.Sprintf("failed to %s: %v", change, err)
+		}
+		report.AddRow(target.name, spec.Key, spec.EnvironmentScope, string(spec.VariableType),
+			strconv.FormatBool(spec.Protected), strconv.FormatBool(spec.Masked), change)
+	}
+	return nil
+}
+
+// findVariable returns the variable with the key and environment scope, if any.
+func findVariable(variables []ciVariable, key, scope string) *ciVariable {
+	for i := range variables {
+		if variables[i].Key == key && variables[i].EnvironmentScope == scope {
+			return &variables[i]
+		}
+	}
+	return nil
+}
+
+// variableChanges returns the names of the fields that differ. Values are compared
+// but never included.
+func variableChanges(current, desired ciVariable) []string {
+	var fields []string
+	if current.Value != desired.Value {
+		fields = append(fields, "value "+hiddenValue)
+	}
+	if current.Description != desired.Description {
+		fields = append(fields, "description")
+	}
+	if current.Protected != desired.Protected {
+		fields = append(fields, "protected")
+	}
+	if current.Masked != desired.Masked {
+		fields = append(fields, "masked")
+	}
+	if current.Raw != desired.Raw {
+		fields = append(fields, "raw")
+	}
+	if current.VariableType != desired.VariableType {
+		fields = append(fields, "variable_type")
+	}
+	return fields
+}
+
+// projectVariableTarget returns the variable operations of a project.
+func projectVariableTarget(client *gitlab.Client, project *gitlab.Project) variableTarget {
+	filter := func(v ciVariable) *gitlab.VariableFilter {
+		return &gitlab.VariableFilter{EnvironmentScope: v.EnvironmentScope}
+	}
+
+	return variableTarget{
+		name: project.PathWithNamespace,
+		list: func() ([]ciVariable, error) {
+			variables, err := listProjectVariables(client, project.ID)
+			if err != nil {
+				return nil, err
+			}
+			result := make([]ciVariable, 0, len(variables))
+			for _, v := range variables {
+				result = append(result, ciVariable{
+					Key:              v.Key,
+					Value:            v.Value,
+					Description:      v.Description,
+					EnvironmentScope: v.EnvironmentScope,
+					Protected:        v.Protected,
+					Masked:           v.Masked,
+					Raw:              v.Raw,
+					VariableType:     v.VariableType,
+				})
+			}
+			return result, nil
+		},
+		create: func(v ciVariable) error {
+			_, _, err := client.ProjectVariables.CreateVariable(project.ID, &gitlab.CreateProjectVariableOptions{
+				Key:              gitlab.String(v.Key),
+				Value:            gitlab.String(v.Value),
+				Description:      gitlab.String(v.Description),
+				EnvironmentScope: gitlab.String(v.EnvironmentScope),
+				Masked:           gitlab.Bool(v.Masked),
+				Protected:        gitlab.Bool(v.Protected),
+				Raw:              gitlab.Bool(v.Raw),
+				VariableType:     gitlab.VariableType(v.VariableType),
+			})
+			return err
+		},
+		update: func(v ciVariable) error {
+			_, _, err := client.ProjectVariables.UpdateVariable(project.ID, v.Key, &gitlab.UpdateProjectVariableOptions{
+				Value:            gitlab.String(v.Value),
+				Description:      gitlab.String(v.Description),
+				EnvironmentScope: gitlab.String(v.EnvironmentScope),
+				Filter:           filter(v),
+				Masked:           gitlab.Bool(v.Masked),
+				Protected:        gitlab.Bool(v.Protected),
+				Raw:              gitlab.Bool(v.Raw),
+				VariableType:     gitlab.VariableType(v.VariableType),
+			})
+			return err
+		},
+		remove: func(v ciVariable) error {
+			_, err := client.ProjectVariables.RemoveVariable(project.ID, v.Key, &gitlab.RemoveProjectVariableOptions{
+				Filter: filter(v),
+			})
+			return err
+		},
+	}
+}
+
+// groupVariableTarget returns the variable operations of a group. The group API of the
+// client has no environment scope filter, so it is added to the query of updates and
+// removals; otherwise GitLab picks any variable with the key.
+func groupVariableTarget(client *gitlab.Client, groupID int, groupName string) variableTarget {
+	return variableTarget{
+		name: groupName,
+		list: func() ([]ciVariable, error) {
+			var result []ciVariable
+			err := Paginate(client, groupID, func(options gitlab.ListOptions) (*gitlab.Response, error) {
+				variables, resp, err := client.GroupVariables.ListVariables(groupID, &gitlab.ListGroupVariablesOptions{
+					Page:    options.Page,
+					PerPage: options.PerPage,
+				})
+				if err != nil {
+					return nil, err
+				}
+				for _, v := range variables {
+					result = append(result, ciVariable{
+						Key:              v.Key,
+						Value:            v.Value,
+						Description:      v.Description,
+						EnvironmentScope: v.EnvironmentScope,
+						Protected:        v.Protected,
+						Masked:           v.Masked,
+						Raw:              v.Raw,
+						VariableType:     v.VariableType,
+					})
+				}
+				return resp, nil
+			})
+			return result, err
+		},
+		create: func(v ciVariable) error {
+			_, _, err := client.GroupVariables.CreateVariable(groupID, &gitlab.CreateGroupVariableOptions{
+				Key:              gitlab.String(v.Key),
+				Value:            gitlab.String(v.Value),
+				Description:      gitlab.String(v.Description),
+				EnvironmentScope: gitlab.String(v.EnvironmentScope),
+				Masked:           gitlab.Bool(v.Masked),
+				Protected:        gitlab.Bool(v.Protected),
+				Raw:              gitlab.Bool(v.Raw),
+				VariableType:     gitlab.VariableType(v.VariableType),
+			})
+			return err
+		},
+		update: func(v ciVariable) error {
+			_, _, err := client.GroupVariables.UpdateVariable(groupID, v.Key, &gitlab.UpdateGroupVariableOptions{
+				Value:            gitlab.String(v.Value),
+				Description:      gitlab.String(v.Description),
+				EnvironmentScope: gitlab.String(v.EnvironmentScope),
+				Masked:           gitlab.Bool(v.Masked),
+				Protected:        gitlab.Bool(v.Protected),
+				Raw:              gitlab.Bool(v.Raw),
+				VariableType:     gitlab.VariableType(v.VariableType),
+			}, environmentScopeFilter(v.EnvironmentScope))
+			return err
+		},
+		remove: func(v ciVariable) error {
+			_, err := client.GroupVariables.RemoveVariable(groupID, v.Key, environmentScopeFilter(v.EnvironmentScope))
+			return err
+		},
+	}
+}
+
+// environmentScopeFilter adds filter[environment_scope] to the query of a request so
+// that it only matches the variable with that environment scope.
+func environmentScopeFilter(scope string) gitlab.RequestOptionFunc {
+	return func(req *retryablehttp.Request) error {
+		query := req.URL.Query()
+		query.Set("filter[environment_scope]", scope)
+		req.URL.RawQuery = query.Encode()
+		return nil
+	}
+}
@@ -0,0 +1,80 @@
+package gitlabapi
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/xanzy/go-gitlab"
+)
+
+// ChangeRequest describes a set of file actions committed on a new branch and
+// proposed with a merge request.
+type ChangeRequest struct {
+	BaseBranch    string // Branch the new branch starts from and the MR targets
+	Branch        string // Branch the commit is created on
+	CommitMessage string
+	Title         string // Merge request title
+	Description   string // Merge request description
+	Actions       []*gitlab.CommitActionOptions
+}
+
+// CommitAndCreateMergeRequest creates the branch and all actions in a single commit
+// and opens a merge request into the base branch. It skips projects where the branch
+// already exists so that reruns do not stack commits on an open merge request.
+func CommitAndCreateMergeRequest(client *gitlab.Client, projectID int, change ChangeRequest) error {
+	exists, err := checkBranchExists(client, projectID, change.Branch)
+	if err != nil {
+		return fmt.Errorf("failed to check if branch exists: %v", err)
+	}
+	if exists {
+		fmt.Printf("Skipping project %d because branch %s already exists\n", projectID, change.Branch)
+		return nil
+	}
+
+	// Create the branch and commit all actions at once
+	err = Retry(maxRetries, retryDelay, func() error {
+		_, _, err := client.Commits.CreateCommit(projectID, &gitlab.CreateCommitOptions{
+			Branch:        gitlab.String(change.Branch),
+			StartBranch:   gitlab.String(change.BaseBranch),
+			CommitMessage: gitlab.String(change.CommitMessage),
+			Actions:       change.Actions,
+		})
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to commit changes for project %d: %v", projectID, err)
+	}
+
+	// Create a merge request
+	var mergeRequest *gitlab.MergeRequest
+	err = Retry(maxRetries, retryDelay, func() error {
+		var err error
+		mergeRequest, _, err = client.MergeRequests.CreateMergeRequest(projectID, &gitlab.CreateMergeRequestOptions{
+			SourceBranch: gitlab.String(change.Branch),
+			TargetBranch: gitlab.String(change.BaseBranch),
+			Title:        gitlab.String(change.Title),
+			Description:  gitlab.String(truncateDescription(change.Description, maxDescriptionSize)),
+		})
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create merge request for project %d: %v", projectID, err)
+	}
+
+	fmt.Printf("Merge request created successfully: %s\n", mergeRequest.WebURL)
+	return nil
+}
+
+// getFileContent returns the raw content of a file at ref and whether it exists.
+func getFileContent(client *gitlab.Client, projectID int, filePath, ref string) ([]byte, bool, error) {
+	content, resp, err := client.RepositoryFiles.GetRawFile(projectID, filePath, &gitlab.GetRawFileOptions{
+		Ref: gitlab.String(ref),
+	})
+	if err != nil {
+		if resp != nil && resp.StatusCode == http.StatusNotFound {
+			return nil, false, nil
+		}
+		return nil, false, fmt.Errorf("failed to read %s: %v", filePath, err)
+	}
+	return content, true, nil
+}
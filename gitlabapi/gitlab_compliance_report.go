@@ -0,0 +1,190 @@
+package gitlabapi
+
+import (
+	"encoding/xml"
+	"fmt"
+	"html"
+	"io"
+	"strings"
+)
+
+// Compliance matrix formats.
+const (
+	FormatMarkdown = "markdown"
+	FormatHTML     = "html"
+)
+
+// ValidateMatrixFormat returns an error if the compliance matrix format is unknown.
+func ValidateMatrixFormat(format string) error {
+	switch format {
+	case "", FormatMarkdown, FormatHTML:
+		return nil
+	}
+	return fmt.Errorf("unknown matrix format: %s", format)
+}
+
+// ComplianceMatrix collects compliance results of all projects.
+type ComplianceMatrix struct {
+	Results  []ComplianceResult
+	projects []string
+	rules    []string
+}
+
+// Add records the results of one project.
+func (m *ComplianceMatrix) Add(results []ComplianceResult) {
+	for _, result := range results {
+		if !containsString(m.projects, result.Project) {
+			m.projects = append(m.projects, result.Project)
+		}
+		if !containsString(m.rules, result.Rule) {
+			m.rules = append(m.rules, result.Rule)
+		}
+		m.Results = append(m.Results, result)
+	}
+}
+
+// Failed returns the number of failed results.
+func (m *ComplianceMatrix) Failed() int {
+	failed := 0
+	for _, result := range m.Results {
+		if !result.Passed {
+			failed++
+		}
+	}
+	return failed
+}
+
+// result returns the result of the rule for the project, if it was evaluated.
+func (m *ComplianceMatrix) result(project, rule string) (ComplianceResult, bool) {
+	for _, result := range m.Results {
+		if result.Project == project && result.Rule == rule {
+			return result, true
+		}
+	}
+	return ComplianceResult{}, false
+}
+
+// WriteMatrix writes the projects × rules matrix as Markdown or HTML.
+func (m *ComplianceMatrix) WriteMatrix(w io.Writer, format string) error {
+	cell := func(project, rule string) string {
+		result, ok := m.result(project, rule)
+		switch {
+		case !ok:
+			return "-"
+		case result.Passed:
+			return "✅"
+		}
+		return "❌"
+	}
+
+	switch format {
+	case "", FormatMarkdown:
+		fmt.Fprintf(w, "| Project | %s |\n", strings.Join(m.rules, " | "))
+		fmt.Fprintf(w, "|---|%s\n", strings.Repeat("---|", len(m.rules)))
+		for _, project := range m.projects {
+			cells := make([]string, len(m.rules))
+			for i, rule := range m.rules {
+				cells[i] = cell(project, rule)
+			}
+			fmt.Fprintf(w, "| %s | %s |\n", project, strings.Join(cells, " | "))
+		}
+		return nil
+	case FormatHTML:
+		fmt.Fprintln(w, "<table>")
+		fmt.Fprint(w, "<tr><th>Project</th>")
+		for _, rule := range m.rules {
+			fmt.Fprintf(w, "<th>%s</th>", html.EscapeString(rule))
+		}
+		fmt.Fprintln(w, "</tr>")
+		for _, project := range m.projects {
+			fmt.Fprintf(w, "<tr><td>%s</td>", html.EscapeString(project))
+			for _, rule := range m.rules {
+				result, _ := m.result(project, rule)
+				fmt.Fprintf(w, "<td title=\"%s\">%s</td>", html.EscapeString(result.Message), cell(project, rule))
+			}
+			fmt.Fprintln(w, "</tr>")
+		}
+		fmt.Fprintln(w, "</table>")
+		return nil
+	}
+	return fmt.Errorf("unknown matrix format: %s", format)
+}
+
+// junitTestSuites is the root element of a JUnit XML report.
+type junitTestSuites struct {
+	XMLName xml.Name         `xml:"testsuites"`
+	Suites  []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name     string          `xml:"name,attr"`
+	Tests    int             `xml:"tests,attr"`
+	Failures int             `xml:"failures,attr"`
+	Cases    []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+}
+
+// WriteJUnit writes the results as JUnit XML with one test suite per project and one
+// test case per rule.
+func (m *ComplianceMatrix) WriteJUnit(w io.Writer) error {
+	report := junitTestSuites{}
+	for _, project := range m.projects {
+		suite := junitTestSuite{Name: project}
+		for _, rule := range m.rules {
+			result, ok := m.result(project, rule)
+			if !ok {
+				continue
+			}
+			testCase := junitTestCase{Name: rule, ClassName: project}
+			if !result.Passed {
+				testCase.Failure = &junitFailure{Message: result.Message}
+				suite.Failures++
+			}
+			suite.Cases = append(suite.Cases, testCase)
+			suite.Tests++
+		}
+		report.Suites = append(report.Suites, suite)
+	}
+
+	fmt.Fprint(w, xml.Header)
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(report); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintln(w)
+	return err
+}
+
+// WriteComplianceFiles writes the matrix to matrixPath (stdout if empty) and the JUnit
+// report to junitPath if it is set.
+func WriteComplianceFiles(m *ComplianceMatrix, matrixFormat, matrixPath, junitPath string) error {
+	if err := writeFile(matrixPath, func(w io.Writer) error {
+		return m.WriteMatrix(w, matrixFormat)
+	}); err != nil {
+		return err
+	}
+	if junitPath == "" {
+		return nil
+	}
+	return writeFile(junitPath, m.WriteJUnit)
+}
+
+// containsString reports whether the slice contains the value.
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
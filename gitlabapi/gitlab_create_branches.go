@@ -2,18 +2,84 @@ package gitlabapi
 
 import (
 	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
 	"github.com/xanzy/go-gitlab"
 )
 
-// CreateBranchAndProtect creates a new branch from a reference branch and protects it
-func CreateBranchAndProtect(client *gitlab.Client, projectID int, refBranch string, newBranch string) error {
-	// Check if the reference branch exists
-	refExists, err := checkBranchExists(client, projectID, refBranch)
+// BranchProtection describes how a protected branch should be configured.
+type BranchProtection struct {
+	Protect                   bool // Create the branch without protecting it if false
+	PushAccessLevel           gitlab.AccessLevelValue
+	MergeAccessLevel          gitlab.AccessLevelValue
+	UnprotectAccessLevel      gitlab.AccessLevelValue
+	AllowedUserIDs            []int // Users allowed to push and merge
+	AllowedGroupIDs           []int // Groups allowed to push and merge
+	PushUserIDs               []int // Users allowed to push only
+	PushGroupIDs              []int // Groups allowed to push only
+	MergeUserIDs              []int // Users allowed to merge only
+	MergeGroupIDs             []int // Groups allowed to merge only
+	AllowForcePush            bool
+	CodeOwnerApprovalRequired bool
+}
+
+// pushAllowed returns the users and groups allowed to push.
+func (p BranchProtection) pushAllowed() (users, groups []int) {
+	return unionIDs(p.AllowedUserIDs, p.PushUserIDs), unionIDs(p.AllowedGroupIDs, p.PushGroupIDs)
+}
+
+// mergeAllowed returns the users and groups allowed to merge.
+func (p BranchProtection) mergeAllowed() (users, groups []int) {
+	return unionIDs(p.AllowedUserIDs, p.MergeUserIDs), unionIDs(p.AllowedGroupIDs, p.MergeGroupIDs)
+}
+
+// DefaultBranchProtection returns the protection used when nothing else is configured:
+// nobody can push and maintainers can merge and unprotect.
+func DefaultBranchProtection() BranchProtection {
+	return BranchProtection{
+		Protect:              true,
+		PushAccessLevel:      gitlab.NoPermissions,
+		MergeAccessLevel:     gitlab.MaintainerPermissions,
+		UnprotectAccessLevel: gitlab.MaintainerPermissions,
+	}
+}
+
+// ParseAccessLevel converts an access level name such as "maintainer" into its value.
+func ParseAccessLevel(name string) (gitlab.AccessLevelValue, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "no", "none", "no-one", "noone":
+		return gitlab.NoPermissions, nil
+	case "minimal":
+		return gitlab.MinimalAccessPermissions, nil
+	case "guest":
+		return gitlab.GuestPermissions, nil
+	case "reporter":
+		return gitlab.ReporterPermissions, nil
+	case "developer":
+		return gitlab.DeveloperPermissions, nil
+	case "maintainer":
+		return gitlab.MaintainerPermissions, nil
+	case "owner":
+		return gitlab.OwnerPermissions, nil
+	case "admin":
+		return gitlab.AdminPermissions, nil
+	}
+	return 0, fmt.Errorf("unknown access level: %s", name)
+}
+
+// CreateBranchAndProtect creates a new branch from a reference (a branch, tag or commit
+// SHA) and protects it as described by protection. If the branch already exists, only
+// its protection is updated when it differs from the requested one.
+func CreateBranchAndProtect(client *gitlab.Client, projectID int, refBranch string, newBranch string, protection BranchProtection) error {
+	// Check if the reference exists
+	refExists, err := checkRefExists(client, projectID, refBranch)
 	if err != nil {
-		return fmt.Errorf("failed to check if reference branch exists: %v", err)
+		return fmt.Errorf("failed to check if reference exists: %v", err)
 	}
 	if !refExists {
-		return fmt.Errorf("reference branch does not exist: %s", refBranch)
+		return fmt.Errorf("reference does not exist: %s", refBranch)
 	}
 
 	// Check if the new branch already exists
@@ -22,8 +88,11 @@ func CreateBranchAndProtect(client *gitlab.Client, projectID int, refBranch stri
 		return fmt.Errorf("failed to check if new branch exists: %v", err)
 	}
 	if newExists {
-		fmt.Printf("Skipping project because branch %s already exists\n", newBranch)
-		return nil
+		if !protection.Protect {
+			fmt.Printf("Skipping project because branch %s already exists\n", newBranch)
+			return nil
+		}
+		return EnsureBranchProtection(client, projectID, newBranch, protection)
 	}
 
 	// Create the branch
@@ -34,21 +103,249 @@ func CreateBranchAndProtect(client *gitlab.Client, projectID int, refBranch stri
 	if err != nil {
 		return fmt.Errorf("failed to create branch: %v", err)
 	}
+	fmt.Printf("Created branch: %s\n", branch.Name)
 
-	// Protect the branch
-	_, _, err = client.ProtectedBranches.ProtectRepositoryBranches(projectID, &gitlab.ProtectRepositoryBranchesOptions{
-		Name: gitlab.String(newBranch),
-		PushAccessLevel: gitlab.AccessLevel(gitlab.NoPermissions),
-		MergeAccessLevel: gitlab.AccessLevel(gitlab.MaintainerPermissions),
-	})
+	if !protection.Protect {
+		return nil
+	}
+
+	return EnsureBranchProtection(client, projectID, newBranch, protection)
+}
+
+// EnsureBranchProtection protects the branch (or wildcard pattern) with the given
+// protection. An existing protection that differs is updated in place; a matching one
+// is left alone.
+func EnsureBranchProtection(client *gitlab.Client, projectID int, branch string, protection BranchProtection) error {
+	current, resp, err := client.ProtectedBranches.GetProtectedBranch(projectID, branch)
+	if err != nil && (resp == nil || resp.StatusCode != http.StatusNotFound) {
+		return fmt.Errorf("failed to get protection of branch %s: %v", branch, err)
+	}
+
+	if err == nil {
+		if protectionMatches(current, protection) {
+			fmt.Printf("Branch %s is already protected as requested\n", branch)
+			return nil
+		}
+
+		_, _, err = client.ProtectedBranches.UpdateProtectedBranch(projectID, branch, updateOptions(current, protection))
+		if err != nil {
+			return fmt.Errorf("failed to update protection of branch %s: %v", branch, err)
+		}
+		fmt.Printf("Updated protection of branch: %s\n", branch)
+		return nil
+	}
+
+	_, _, err = client.ProtectedBranches.ProtectRepositoryBranches(projectID, protectOptions(branch, protection))
 	if err != nil {
 		return fmt.Errorf("failed to protect branch: %v", err)
 	}
-
-	fmt.Printf("Created branch: %s\n", branch.Name)
+	fmt.Printf("Protected branch: %s\n", branch)
 	return nil
 }
 
+// protectOptions builds the protect request for the branch from the protection. The
+// Premium-only settings are only sent when they are configured.
+func protectOptions(branch string, protection BranchProtection) *gitlab.ProtectRepositoryBranchesOptions {
+	options := &gitlab.ProtectRepositoryBranchesOptions{
+		Name:             gitlab.String(branch),
+		PushAccessLevel:  gitlab.AccessLevel(protection.PushAccessLevel),
+		MergeAccessLevel: gitlab.AccessLevel(protection.MergeAccessLevel),
+		AllowForcePush:   gitlab.Bool(protection.AllowForcePush),
+	}
+	if protection.UnprotectAccessLevel != gitlab.NoPermissions {
+		options.UnprotectAccessLevel = gitlab.AccessLevel(protection.UnprotectAccessLevel)
+	}
+	if protection.CodeOwnerApprovalRequired {
+		options.CodeOwnerApprovalRequired = gitlab.Bool(true)
+	}
+	if allowed := permissionOptions(protection.pushAllowed()); len(allowed) > 0 {
+		options.AllowedToPush = &allowed
+	}
+	if allowed := permissionOptions(protection.mergeAllowed()); len(allowed) > 0 {
+		options.AllowedToMerge = &allowed
+	}
+	return options
+}
+
+// permissionOptions converts users and groups into branch permissions.
+func permissionOptions(users, groups []int) []*gitlab.BranchPermissionOptions {
+	var allowed []*gitlab.BranchPermissionOptions
+	for _, id := range users {
+		allowed = append(allowed, &gitlab.BranchPermissionOptions{UserID: gitlab.Int(id)})
+	}
+	for _, id := range groups {
+		allowed = append(allowed, &gitlab.BranchPermissionOptions{GroupID: gitlab.Int(id)})
+	}
+	return allowed
+}
+
+// updateOptions builds the update request that turns the current protection into the
+// requested one. Access entries that are no longer wanted are destroyed by ID and the
+// missing ones are added, so the branch stays protected while it is updated. Unmanaged
+// settings are left untouched.
+func updateOptions(current *gitlab.ProtectedBranch, protection BranchProtection) *gitlab.UpdateProtectedBranchOptions {
+	pushUsers, pushGroups := protection.pushAllowed()
+	mergeUsers, mergeGroups := protection.mergeAllowed()
+	options := &gitlab.UpdateProtectedBranchOptions{
+		AllowForcePush: gitlab.Bool(protection.AllowForcePush),
+		AllowedToPush:  permissionChanges(current.PushAccessLevels, protection.PushAccessLevel, pushUsers, pushGroups),
+		AllowedToMerge: permissionChanges(current.MergeAccessLevels, protection.MergeAccessLevel, mergeUsers, mergeGroups),
+	}
+	if unprotectManaged(current, protection) {
+		options.AllowedToUnprotect = permissionChanges(current.UnprotectAccessLevels, protection.UnprotectAccessLevel, nil, nil)
+	}
+	if protection.CodeOwnerApprovalRequired {
+		options.CodeOwnerApprovalRequired = gitlab.Bool(true)
+	}
+	return options
+}
+
+// unprotectManaged reports whether the unprotect access of the branch is compared and
+// updated. It is unmanaged when no level is requested or when GitLab does not report
+// one, as only Premium supports it.
+func unprotectManaged(current *gitlab.ProtectedBranch, protection BranchProtection) bool {
+	return protection.UnprotectAccessLevel != gitlab.NoPermissions && len(current.UnprotectAccessLevels) > 0
+}
+
+// permissionChanges returns the entries that destroy the access descriptions of one kind
+// that are not expected and add the expected role, users and groups that are missing.
+func permissionChanges(levels []*gitlab.BranchAccessDescription, role gitlab.AccessLevelValue, users, groups []int) *[]*gitlab.BranchPermissionOptions {
+	changes := []*gitlab.BranchPermissionOptions{}
+	hasRole := false
+	hasUsers := map[int]bool{}
+	hasGroups := map[int]bool{}
+	for _, level := range levels {
+		keep := false
+		switch {
+		case level.UserID != 0:
+			keep = containsID(users, level.UserID) && !hasUsers[level.UserID]
+			hasUsers[level.UserID] = hasUsers[level.UserID] || keep
+		case level.GroupID != 0:
+			keep = containsID(groups, level.GroupID) && !hasGroups[level.GroupID]
+			hasGroups[level.GroupID] = hasGroups[level.GroupID] || keep
+		default:
+			keep = level.AccessLevel == role && !hasRole
+			hasRole = hasRole || keep
+		}
+		if !keep {
+			changes = append(changes, &gitlab.BranchPermissionOptions{ID: gitlab.Int(level.ID), Destroy: gitlab.Bool(true)})
+		}
+	}
+
+	if !hasRole {
+		changes = append(changes, &gitlab.BranchPermissionOptions{AccessLevel: gitlab.AccessLevel(role)})
+	}
+	for _, id := range users {
+		if !hasUsers[id] {
+			hasUsers[id] = true
+			changes = append(changes, &gitlab.BranchPermissionOptions{UserID: gitlab.Int(id)})
+		}
+	}
+	for _, id := range groups {
+		if !hasGroups[id] {
+			hasGroups[id] = true
+			changes = append(changes, &gitlab.BranchPermissionOptions{GroupID: gitlab.Int(id)})
+		}
+	}
+	return &changes
+}
+
+// protectionMatches reports whether the existing protected branch matches the protection.
+// The Premium-only unprotect access and code owner approval are only compared when they
+// are managed, so GitLab Free does not report a difference on every run.
+func protectionMatches(current *gitlab.ProtectedBranch, protection BranchProtection) bool {
+	if current.AllowForcePush != protection.AllowForcePush ||
+		(protection.CodeOwnerApprovalRequired && !current.CodeOwnerApprovalRequired) {
+		return false
+	}
+
+	pushUsers, pushGroups := protection.pushAllowed()
+	mergeUsers, mergeGroups := protection.mergeAllowed()
+	if !accessMatches(current.PushAccessLevels, protection.PushAccessLevel, pushUsers, pushGroups) ||
+		!accessMatches(current.MergeAccessLevels, protection.MergeAccessLevel, mergeUsers, mergeGroups) {
+		return false
+	}
+
+	if !unprotectManaged(current, protection) {
+		return true
+	}
+
+	// Unprotect access only supports roles; users and groups are not granted there
+	return accessMatches(current.UnprotectAccessLevels, protection.UnprotectAccessLevel, nil, nil)
+}
+
+// accessMatches compares the access descriptions of one kind (push, merge or unprotect)
+// with the role, users and groups that are expected.
+func accessMatches(levels []*gitlab.BranchAccessDescription, role gitlab.AccessLevelValue, allowedUsers, allowedGroups []int) bool {
+	roles := []int{}
+	users := []int{}
+	groups := []int{}
+	for _, level := range levels {
+		switch {
+		case level.UserID != 0:
+			users = append(users, level.UserID)
+		case level.GroupID != 0:
+			groups = append(groups, level.GroupID)
+		default:
+			roles = append(roles, int(level.AccessLevel))
+		}
+	}
+
+	return equalIDs(roles, []int{int(role)}) &&
+		equalIDs(users, allowedUsers) &&
+		equalIDs(groups, allowedGroups)
+}
+
+// unionIDs returns the IDs of both slices without duplicates, in order.
+func unionIDs(a, b []int) []int {
+	var ids []int
+	for _, id := range append(append([]int(nil), a...), b...) {
+		if !containsID(ids, id) {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// containsID reports whether the ID is in the slice.
+func containsID(ids []int, id int) bool {
+	for _, i := range ids {
+		if i == id {
+			return true
+		}
+	}
+	return false
+}
+
+// equalIDs reports whether both slices contain the same IDs, ignoring order.
+func equalIDs(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	a = append([]int(nil), a...)
+	b = append([]int(nil), b...)
+	sort.Ints(a)
+	sort.Ints(b)
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// checkRefExists checks if a branch, tag or commit SHA resolves to a commit in the project
+func checkRefExists(client *gitlab.Client, projectID int, ref string) (bool, error) {
+	_, resp, err := client.Commits.GetCommit(projectID, ref)
+	if err != nil {
+		if resp != nil && resp.StatusCode == http.StatusNotFound {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
 // checkBranchExists checks if a branch exists in the project
 func checkBranchExists(client *gitlab.Client, projectID int, branchName string) (bool, error) {
 	branches, _, err := client.Branches.ListBranches(projectID, &gitlab.ListBranchesOptions{
@@ -65,4 +362,4 @@ func checkBranchExists(client *gitlab.Client, projectID int, branchName string)
 	}
 
 	return false, nil
-}
\ No newline at end of file
+}
@@ -0,0 +1,41 @@
+package gitlabapi
+
+import (
+	"testing"
+
+	"github.com/xanzy/go-gitlab"
+)
+
+func TestProtectionMatches(t *testing.T) {
+	free := &gitlab.ProtectedBranch{
+		PushAccessLevels:  []*gitlab.BranchAccessDescription{{AccessLevel: gitlab.NoPermissions}},
+		MergeAccessLevels: []*gitlab.BranchAccessDescription{{AccessLevel: gitlab.MaintainerPermissions}},
+	}
+	premium := &gitlab.ProtectedBranch{
+		PushAccessLevels:          free.PushAccessLevels,
+		MergeAccessLevels:         free.MergeAccessLevels,
+		UnprotectAccessLevels:     []*gitlab.BranchAccessDescription{{AccessLevel: gitlab.DeveloperPermissions}},
+		CodeOwnerApprovalRequired: true,
+	}
+
+	tests := []struct {
+		name       string
+		current    *gitlab.ProtectedBranch
+		protection BranchProtection
+		want       bool
+	}{
+		{"free ignores unprotect", free, DefaultBranchProtection(), true},
+		{"unprotect differs", premium, DefaultBranchProtection(), false},
+		{"unprotect unset", premium, BranchProtection{PushAccessLevel: gitlab.NoPermissions, MergeAccessLevel: gitlab.MaintainerPermissions}, true},
+		{"code owner approval missing", free, BranchProtection{PushAccessLevel: gitlab.NoPermissions, MergeAccessLevel: gitlab.MaintainerPermissions, CodeOwnerApprovalRequired: true}, false},
+		{"merge differs", free, BranchProtection{PushAccessLevel: gitlab.NoPermissions, MergeAccessLevel: gitlab.DeveloperPermissions}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := protectionMatches(tt.current, tt.protection); got != tt.want {
+				t.Errorf("protectionMatches() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -2,26 +2,119 @@ package gitlabapi
 
 import (
 	"fmt"
-	"log"
+	"regexp"
+	"strings"
 
 	"github.com/xanzy/go-gitlab"
 )
 
-// TriggerPipeline triggers a pipeline for a given project and branch.
-// It returns an error if the pipeline creation fails.
-func CreateMerge(client *gitlab.Client, projectID int, sourceBranch, targetBranch string) error {
-	// Create a new pipeline
+const (
+	maxDescriptionSize = 10000 // Maximum size of a generated merge request description
+)
+
+// issueRefRegex matches issue references such as #12 or group/project#12 in commit messages.
+var issueRefRegex = regexp.MustCompile(`(?:[\w.\-]+/[\w.\-/]+)?#\d+`)
+
+// CreateMerge creates a merge request from the source branch into the target branch.
+// If describe is true, the description is built from the commits and changed files
+// between the two branches.
+func CreateMerge(client *gitlab.Client, projectID int, sourceBranch, targetBranch string, describe bool) error {
 	title := fmt.Sprintf("Merge %s into %s", sourceBranch, targetBranch)
-	mergeRequest, _, err := client.MergeRequests.CreateMergeRequest(projectID, &gitlab.CreateMergeRequestOptions{
+	options := &gitlab.CreateMergeRequestOptions{
 		SourceBranch: gitlab.String(sourceBranch),
 		TargetBranch: gitlab.String(targetBranch),
 		Title:        gitlab.String(title),
-	})
+	}
+
+	if describe {
+		description, err := buildMergeDescription(client, projectID, sourceBranch, targetBranch)
+		if err != nil {
+			return fmt.Errorf("failed to build merge request description: %v", err)
+		}
+		options.Description = gitlab.String(description)
+	}
+
+	mergeRequest, _, err := client.MergeRequests.CreateMergeRequest(projectID, options)
 	if err != nil {
-		log.Fatalf("Failed to create merge request: %v", err)
+		return fmt.Errorf("failed to create merge request: %v", err)
 	}
 
 	fmt.Printf("Merge request created successfully: %s\n", mergeRequest.WebURL)
 
 	return nil
 }
+
+// buildMergeDescription compares the target branch with the source branch and renders
+// the commit titles, changed files and referenced issues as Markdown.
+func buildMergeDescription(client *gitlab.Client, projectID int, sourceBranch, targetBranch string) (string, error) {
+	compare, _, err := client.Repositories.Compare(projectID, &gitlab.CompareOptions{
+		From: gitlab.String(targetBranch),
+		To:   gitlab.String(sourceBranch),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	var sb strings.Builder
+
+	sb.WriteString("## Commits\n\n")
+	issues := []string{}
+	seen := map[string]bool{}
+	for _, commit := range compare.Commits {
+		fmt.Fprintf(&sb, "- %s %s\n", commit.ShortID, commit.Title)
+		for _, ref := range issueRefRegex.FindAllString(commit.Message, -1) {
+			if !seen[ref] {
+				seen[ref] = true
+				issues = append(issues, ref)
+			}
+		}
+	}
+
+	sb.WriteString("\n## Changed files\n\n")
+	for _, diff := range compare.Diffs {
+		added, removed := countDiffLines(diff.Diff)
+		path := diff.NewPath
+		if diff.RenamedFile {
+			path = fmt.Sprintf("%s → %s", diff.OldPath, diff.NewPath)
+		}
+		fmt.Fprintf(&sb, "- `%s` (+%d/-%d)\n", path, added, removed)
+	}
+
+	if len(issues) > 0 {
+		sb.WriteString("\n## Related issues\n\n")
+		for _, ref := range issues {
+			fmt.Fprintf(&sb, "- %s\n", ref)
+		}
+	}
+
+	return truncateDescription(sb.String(), maxDescriptionSize), nil
+}
+
+// countDiffLines counts the added and removed lines of a unified diff.
+func countDiffLines(diff string) (added, removed int) {
+	for _, line := range strings.Split(diff, "\n") {
+		switch {
+		case strings.HasPrefix(line, "+++"), strings.HasPrefix(line, "---"):
+			continue
+		case strings.HasPrefix(line, "+"):
+			added++
+		case strings.HasPrefix(line, "-"):
+			removed++
+		}
+	}
+	return added, removed
+}
+
+// truncateDescription cuts the description at the last full line that fits in limit.
+func truncateDescription(description string, limit int) string {
+	if len(description) <= limit {
+		return description
+	}
+
+	const notice = "\n_Description truncated._\n"
+	cut := description[:limit-len(notice)]
+	if i := strings.LastIndex(cut, "\n"); i > 0 {
+		cut = cut[:i]
+	}
+	return cut + notice
+}
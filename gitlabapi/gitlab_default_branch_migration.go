@@ -0,0 +1,243 @@
+package gitlabapi
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/xanzy/go-gitlab"
+)
+
+// What happens to the old default branch after a migration.
+const (
+	OldBranchKeep    = "keep"    // Leave the old branch as it is
+	OldBranchProtect = "protect" // Protect the old branch so nobody can push to it
+	OldBranchDelete  = "delete"  // Delete the old branch
+)
+
+// DefaultBranchMigration describes the move of projects to a new default branch.
+type DefaultBranchMigration struct {
+	OldBranch string // Defaults to the project's current default branch
+	NewBranch string
+	OldAction string // OldBranchKeep, OldBranchProtect or OldBranchDelete
+	DryRun    bool
+}
+
+// NewMigrationReport returns an empty report with one row per migration step.
+func NewMigrationReport() *Report {
+	return &Report{Headers: []string{"Project", "Old Branch", "New Branch", "Step", "Result"}}
+}
+
+// MigrateDefaultBranch creates the new branch from the old one with the same protection,
+// makes it the default branch and retargets the open merge requests. References to the
+// old branch in .gitlab-ci.yml are only reported, not changed. Finally the old branch is
+// kept, protected or deleted. In dry-run mode the steps are only reported. The migration
+// stops when the branch cannot be created or made the default; an error is returned if
+// any step failed.
+func MigrateDefaultBranch(client *gitlab.Client, project *gitlab.Project, migration DefaultBranchMigration, report *Report) error {
+	oldBranch := migration.OldBranch
+	if oldBranch == "" {
+		oldBranch = project.DefaultBranch
+	}
+	newBranch := migration.NewBranch
+
+	// step runs f unless in dry-run mode, reports the result and returns the error
+	var failedSteps []string
+	step := func(name string, f func() error) error {
+		result := "ok"
+		var err error
+		if migration.DryRun {
+			result = "dry run"
+		} else if err = f(); err != nil {
+			result = err.Error()
+			failedSteps = append(failedSteps, name)
+		}
+		report.AddRow(project.PathWithNamespace, oldBranch, newBranch, name, result)
+		return err
+	}
+	note := func(name, result string) {
+		report.AddRow(project.PathWithNamespace, oldBranch, newBranch, name, result)
+	}
+
+	if oldBranch == newBranch {
+		note("check", "already on "+newBranch)
+		return nil
+	}
+
+	oldExists, err := checkBranchExists(client, project.ID, oldBranch)
+	if err != nil {
+		return fmt.Errorf("failed to check if branch %s exists: %v", oldBranch, err)
+	}
+	if !oldExists {
+		note("check", "branch "+oldBranch+" does not exist")
+		return nil
+	}
+
+	newExists, err := checkBranchExists(client, project.ID, newBranch)
+	if err != nil {
+		return fmt.Errorf("failed to check if branch %s exists: %v", newBranch, err)
+	}
+	if newExists {
+		note("create branch", "already exists")
+	} else if err := step("create branch", func() error {
+		_, _, err := client.Branches.CreateBranch(project.ID, &gitlab.CreateBranchOptions{
+			Branch: gitlab.String(newBranch),
+			Ref:    gitlab.String(oldBranch),
+		})
+		return err
+	}); err != nil {
+		return fmt.Errorf("failed to create branch %s: %v", newBranch, err)
+	}
+
+	current, resp, err := client.ProtectedBranches.GetProtectedBranch(project.ID, oldBranch)
+	switch {
+	case err == nil:
+		step("copy protection", func() error {
+			return EnsureBranchProtection(client, project.ID, newBranch, protectionFromBranch(current))
+		})
+	case resp != nil && resp.StatusCode == http.StatusNotFound:
+		note("copy protection", "old branch is not protected")
+	default:
+		return fmt.Errorf("failed to get protection of branch %s: %v", oldBranch, err)
+	}
+
+	if project.DefaultBranch == newBranch {
+		note("set default branch", "already default")
+	} else if err := step("set default branch", func() error {
+		_, _, err := client.Projects.EditProject(project.ID, &gitlab.EditProjectOptions{
+			DefaultBranch: gitlab.String(newBranch),
+		})
+		return err
+	}); err != nil {
+		return fmt.Errorf("failed to set default branch %s: %v", newBranch, err)
+	}
+
+	mergeRequests, err := openMergeRequestsTargeting(client, project.ID, oldBranch)
+	if err != nil {
+		return err
+	}
+	for _, mergeRequest := range mergeRequests {
+		iid := mergeRequest.IID
+		step("retarget !"+strconv.Itoa(iid), func() error {
+			_, _, err := client.MergeRequests.UpdateMergeRequest(project.ID, iid, &gitlab.UpdateMergeRequestOptions{
+				TargetBranch: gitlab.String(newBranch),
+			})
+			return err
+		})
+	}
+
+	references, err := ciReferences(client, project.ID, oldBranch)
+	if err != nil {
+		return err
+	}
+	for _, reference := range references {
+		note(".gitlab-ci.yml", reference)
+	}
+
+	switch migration.OldAction {
+	case OldBranchProtect:
+		step("protect old branch", func() error {
+			return EnsureBranchProtection(client, project.ID, oldBranch, DefaultBranchProtection())
+		})
+	case OldBranchDelete:
+		// The old branch is the only way back while the migration is incomplete
+		if len(failedSteps) > 0 {
+			note("delete old branch", "skipped: earlier steps failed")
+			break
+		}
+		step("delete old branch", func() error {
+			if current != nil {
+				if err := UnprotectBranch(client, project.ID, oldBranch); err != nil {
+					return err
+				}
+			}
+			_, err := client.Branches.DeleteBranch(project.ID, oldBranch)
+			return err
+		})
+	}
+
+	if len(failedSteps) > 0 {
+		return fmt.Errorf("failed steps: %s", strings.Join(failedSteps, ", "))
+	}
+	return nil
+}
+
+// protectionFromBranch converts an existing protected branch into a BranchProtection.
+// Users and groups allowed to push and those allowed to merge are kept apart, so the
+// copy grants exactly the same permissions.
+func protectionFromBranch(branch *gitlab.ProtectedBranch) BranchProtection {
+	protection := BranchProtection{
+		Protect:                   true,
+		AllowForcePush:            branch.AllowForcePush,
+		CodeOwnerApprovalRequired: branch.CodeOwnerApprovalRequired,
+	}
+
+	role := func(levels []*gitlab.BranchAccessDescription) gitlab.AccessLevelValue {
+		for _, level := range levels {
+			if level.UserID == 0 && level.GroupID == 0 {
+				return level.AccessLevel
+			}
+		}
+		return gitlab.NoPermissions
+	}
+	protection.PushAccessLevel = role(branch.PushAccessLevels)
+	protection.MergeAccessLevel = role(branch.MergeAccessLevels)
+	// Without Premium there is no unprotect level, which leaves it unmanaged on the copy
+	protection.UnprotectAccessLevel = role(branch.UnprotectAccessLevels)
+
+	allowed := func(levels []*gitlab.BranchAccessDescription) (users, groups []int) {
+		for _, level := range levels {
+			if level.UserID != 0 && !containsID(users, level.UserID) {
+				users = append(users, level.UserID)
+			}
+			if level.GroupID != 0 && !containsID(groups, level.GroupID) {
+				groups = append(groups, level.GroupID)
+			}
+		}
+		return users, groups
+	}
+	protection.PushUserIDs, protection.PushGroupIDs = allowed(branch.PushAccessLevels)
+	protection.MergeUserIDs, protection.MergeGroupIDs = allowed(branch.MergeAccessLevels)
+	return protection
+}
+
+// openMergeRequestsTargeting returns the open merge requests whose target is the branch.
+func openMergeRequestsTargeting(client *gitlab.Client, projectID int, branch string) ([]*gitlab.MergeRequest, error) {
+	var mergeRequests []*gitlab.MergeRequest
+	err := Paginate(client, projectID, func(options gitlab.ListOptions) (*gitlab.Response, error) {
+		page, resp, err := client.MergeRequests.ListProjectMergeRequests(projectID, &gitlab.ListProjectMergeRequestsOptions{
+			ListOptions:  options,
+			State:        gitlab.String("opened"),
+			TargetBranch: gitlab.String(branch),
+		})
+		if err != nil {
+			return nil, err
+		}
+		mergeRequests = append(mergeRequests, page...)
+		return resp, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list merge requests targeting %s: %v", branch, err)
+	}
+	return mergeRequests, nil
+}
+
+// ciReferences returns the lines of .gitlab-ci.yml on the branch that mention the
+// branch name, prefixed with their line number.
+func ciReferences(client *gitlab.Client, projectID int, branch string) ([]string, error) {
+	content, exists, err := getFileContent(client, projectID, ".gitlab-ci.yml", branch)
+	if err != nil || !exists {
+		return nil, err
+	}
+
+	nameRegex := regexp.MustCompile(`(^|[^\w/.-])` + regexp.QuoteMeta(branch) + `($|[^\w/.-])`)
+	var references []string
+	for i, line := range strings.Split(string(content), "\n") {
+		if nameRegex.MatchString(line) {
+			references = append(references, fmt.Sprintf("line %d references %s: %s", i+1, branch, strings.TrimSpace(line)))
+		}
+	}
+	return references, nil
+}
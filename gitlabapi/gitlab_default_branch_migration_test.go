@@ -0,0 +1,67 @@
+package gitlabapi
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/xanzy/go-gitlab"
+)
+
+func TestProtectionFromBranch(t *testing.T) {
+	tests := []struct {
+		name   string
+		branch *gitlab.ProtectedBranch
+		want   BranchProtection
+	}{
+		{
+			name: "roles only",
+			branch: &gitlab.ProtectedBranch{
+				PushAccessLevels:      []*gitlab.BranchAccessDescription{{AccessLevel: gitlab.NoPermissions}},
+				MergeAccessLevels:     []*gitlab.BranchAccessDescription{{AccessLevel: gitlab.MaintainerPermissions}},
+				UnprotectAccessLevels: []*gitlab.BranchAccessDescription{{AccessLevel: gitlab.OwnerPermissions}},
+				AllowForcePush:        true,
+			},
+			want: BranchProtection{
+				Protect:              true,
+				PushAccessLevel:      gitlab.NoPermissions,
+				MergeAccessLevel:     gitlab.MaintainerPermissions,
+				UnprotectAccessLevel: gitlab.OwnerPermissions,
+				AllowForcePush:       true,
+			},
+		},
+		{
+			name: "push and merge users stay apart",
+			branch: &gitlab.ProtectedBranch{
+				PushAccessLevels: []*gitlab.BranchAccessDescription{
+					{AccessLevel: gitlab.DeveloperPermissions},
+					{UserID: 1},
+					{GroupID: 10},
+				},
+				MergeAccessLevels: []*gitlab.BranchAccessDescription{
+					{AccessLevel: gitlab.MaintainerPermissions},
+					{UserID: 2},
+					{UserID: 2},
+				},
+				CodeOwnerApprovalRequired: true,
+			},
+			want: BranchProtection{
+				Protect:                   true,
+				PushAccessLevel:           gitlab.DeveloperPermissions,
+				MergeAccessLevel:          gitlab.MaintainerPermissions,
+				PushUserIDs:               []int{1},
+				PushGroupIDs:              []int{10},
+				MergeUserIDs:              []int{2},
+				CodeOwnerApprovalRequired: true,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := protectionFromBranch(tt.branch)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("protectionFromBranch() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -5,81 +5,94 @@ package gitlabapi
 import (
 	"fmt"
 	"log"
-	"regexp"
+	"strings"
 
 	"github.com/xanzy/go-gitlab"
 )
 
+// PurgeOptions describes which files are removed by PurgeFiles.
+type PurgeOptions struct {
+	BaseBranch string // Defaults to the project's default branch
+	Branch     string
+	Matcher    *PathMatcher
+	MinSize    int // Only purge files of at least this many bytes, 0 purges any size
+}
+
 // DeleteCarFilesAndCreateMergeRequest deletes .car files from the specified project
-// and creates a merge request with the deletions, if any .car files are found.
-func DeleteCarFilesAndCreateMergeRequest(client *gitlab.Client, projectID int) error {
-	// Checkout to a new feature branch if it doesn't exist
-	branchName := "feature/delete-car-files"
-	ref, _, err := client.Branches.GetBranch(projectID, branchName)
-	if err != nil || ref == nil {
-		// Create a new branch if it doesn't exist
-		_, _, err = client.Branches.CreateBranch(projectID, &gitlab.CreateBranchOptions{
-			Branch: gitlab.String(branchName),
-			Ref:    gitlab.String("develop"),
-		})
-		if err != nil {
-			return fmt.Errorf("failed to create branch for project %d: %v", projectID, err)
-		}
+// and creates a merge request against the default branch with the deletions, if any
+// .car files are found.
+func DeleteCarFilesAndCreateMergeRequest(client *gitlab.Client, project *gitlab.Project) error {
+	matcher, err := NewPathMatcher([]string{`re:\.car$`}, nil, "")
+	if err != nil {
+		return err
 	}
 
-	// List all files in the project
-	tree, _, err := client.Repositories.ListTree(projectID, &gitlab.ListTreeOptions{
-		Ref:       gitlab.String(branchName),
-		Recursive: gitlab.Bool(true),
+	return PurgeFiles(client, project, PurgeOptions{
+		Branch:  "feature/delete-car-files",
+		Matcher: matcher,
 	})
+}
+
+// PurgeFiles deletes every file matching the options in a single commit on a new
+// branch and opens a merge request listing the removed paths. Projects without
+// matching files are skipped.
+func PurgeFiles(client *gitlab.Client, project *gitlab.Project, options PurgeOptions) error {
+	projectID := project.ID
+	baseBranch := options.BaseBranch
+	if baseBranch == "" {
+		baseBranch = project.DefaultBranch
+	}
+
+	files, err := listRepositoryFiles(client, projectID, baseBranch)
 	if err != nil {
-		return fmt.Errorf("failed to list files for project %d: %v", projectID, err)
+		return err
 	}
 
-	// Filter files to find those that match the regex pattern
-	carRegex := regexp.MustCompile(`\.car$`)
-	deletedFiles := false
-	for _, item := range tree {
-		if carRegex.MatchString(item.Path) && item.Type == "blob" {
-			// Delete the matched file
-			_, err := client.RepositoryFiles.DeleteFile(projectID, item.Path, &gitlab.DeleteFileOptions{
-				Branch:        gitlab.String(branchName),
-				CommitMessage: gitlab.String("Delete car file"),
+	var actions []*gitlab.CommitActionOptions
+	var removed []string
+	for _, file := range files {
+		if !options.Matcher.Match(file.Path) {
+			continue
+		}
+
+		if options.MinSize > 0 {
+			meta, _, err := client.RepositoryFiles.GetFileMetaData(projectID, file.Path, &gitlab.GetFileMetaDataOptions{
+				Ref: gitlab.String(baseBranch),
 			})
 			if err != nil {
-				log.Printf("Failed to delete file %s for project %d: %v\n", item.Path, projectID, err)
+				log.Printf("Failed to get size of file %s for project %d: %v\n", file.Path, projectID, err)
+				continue
+			}
+			if meta.Size < options.MinSize {
 				continue
 			}
-			deletedFiles = true
 		}
+
+		actions = append(actions, &gitlab.CommitActionOptions{
+			Action:   gitlab.FileAction(gitlab.FileDelete),
+			FilePath: gitlab.String(file.Path),
+		})
+		removed = append(removed, file.Path)
 	}
 
-	// If no .car files were deleted, skip the project
-	if !deletedFiles {
-		log.Printf("No .car files found for project %d, skipping project\n", projectID)
+	// If no files matched, skip the project
+	if len(actions) == 0 {
+		log.Printf("No matching files found for project %d, skipping project\n", projectID)
 		return nil
 	}
 
-	// Commit the changes
-	_, _, err = client.Commits.CreateCommit(projectID, &gitlab.CreateCommitOptions{
-		Branch:        gitlab.String(branchName),
-		CommitMessage: gitlab.String("Delete car files"),
-	})
-	if err != nil {
-		return fmt.Errorf("failed to commit changes for project %d: %v", projectID, err)
+	var description strings.Builder
+	fmt.Fprintf(&description, "Removes %d file(s):\n\n", len(removed))
+	for _, path := range removed {
+		fmt.Fprintf(&description, "- `%s`\n", path)
 	}
 
-	// Create a merge request
-	targetBranch := "develop" // The branch you want to merge into
-	title := fmt.Sprintf("Merge request to delete car files")
-	_, _, err = client.MergeRequests.CreateMergeRequest(projectID, &gitlab.CreateMergeRequestOptions{
-		SourceBranch: gitlab.String(branchName),
-		TargetBranch: gitlab.String(targetBranch),
-		Title:        gitlab.String(title),
+	return CommitAndCreateMergeRequest(client, projectID, ChangeRequest{
+		BaseBranch:    baseBranch,
+		Branch:        options.Branch,
+		CommitMessage: fmt.Sprintf("Delete %d matching file(s)", len(removed)),
+		Title:         fmt.Sprintf("Merge request to delete %d matching file(s)", len(removed)),
+		Description:   description.String(),
+		Actions:       actions,
 	})
-	if err != nil {
-		return fmt.Errorf("failed to create merge request for project %d: %v", projectID, err)
-	}
-
-	return nil
 }
@@ -0,0 +1,50 @@
+package gitlabapi
+
+import (
+	"fmt"
+)
+
+// FieldDrift is a setting whose current value differs from the desired one.
+type FieldDrift struct {
+	Field   string
+	Current string
+	Desired string
+}
+
+func (d FieldDrift) String() string {
+	return fmt.Sprintf("%s: %s -> %s", d.Field, d.Current, d.Desired)
+}
+
+// driftList collects the fields whose desired value is set and differs from the current one.
+type driftList []FieldDrift
+
+// compare records a drift for field when desired is set and differs from current.
+func compare[T comparable](drifts *driftList, field string, current T, desired *T) {
+	if desired != nil && *desired != current {
+		*drifts = append(*drifts, FieldDrift{
+			Field:   field,
+			Current: fmt.Sprintf("%v", current),
+			Desired: fmt.Sprintf("%v", *desired),
+		})
+	}
+}
+
+// printDrift prints the drift of a project, or that it is in sync.
+func printDrift(projectName string, drifts []FieldDrift) {
+	if len(drifts) == 0 {
+		fmt.Printf("Project %s is in sync\n", projectName)
+		return
+	}
+	fmt.Printf("Project %s has %d drifted setting(s):\n", projectName, len(drifts))
+	for _, drift := range drifts {
+		fmt.Printf("  %s\n", drift)
+	}
+}
+
+// changed returns desired when it is set and differs from current, and nil otherwise.
+func changed[T comparable](current T, desired *T) *T {
+	if desired != nil && *desired != current {
+		return desired
+	}
+	return nil
+}
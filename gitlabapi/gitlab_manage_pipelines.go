@@ -0,0 +1,105 @@
+package gitlabapi
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/xanzy/go-gitlab"
+)
+
+// Operations on existing pipelines.
+const (
+	RetryPipelines  = "retry"  // Retry the failed jobs of failed pipelines
+	CancelPipelines = "cancel" // Cancel running and pending pipelines
+)
+
+// PipelineFilter selects the pipelines of a project to operate on.
+type PipelineFilter struct {
+	Ref           string     // Any ref if empty
+	Statuses      []string   // Defaults to failed for retry and running/pending for cancel
+	UpdatedAfter  *time.Time // Required so that a run never touches the whole history
+	UpdatedBefore *time.Time
+}
+
+// NewPipelineOperationReport returns an empty report with the retry/cancel columns.
+func NewPipelineOperationReport() *Report {
+	return &Report{Headers: []string{"Project", "Pipeline", "Ref", "Status", "Action", "Result"}}
+}
+
+// FindPipelines returns the pipelines of the project that match the filter and the
+// operation. The filter must be limited by UpdatedAfter.
+func FindPipelines(client *gitlab.Client, project *gitlab.Project, operation string, filter PipelineFilter) ([]*gitlab.PipelineInfo, error) {
+	if filter.UpdatedAfter == nil {
+		return nil, fmt.Errorf("pipelines must be limited to a time window")
+	}
+
+	statuses := filter.Statuses
+	if len(statuses) == 0 {
+		switch operation {
+		case RetryPipelines:
+			statuses = []string{"failed"}
+		case CancelPipelines:
+			statuses = []string{"running", "pending"}
+		default:
+			return nil, fmt.Errorf("unknown pipeline operation: %s", operation)
+		}
+	}
+
+	var pipelines []*gitlab.PipelineInfo
+	for _, status := range statuses {
+		err := Paginate(client, project.ID, func(listOptions gitlab.ListOptions) (*gitlab.Response, error) {
+			options := &gitlab.ListProjectPipelinesOptions{
+				ListOptions:   listOptions,
+				Status:        gitlab.BuildState(gitlab.BuildStateValue(status)),
+				UpdatedAfter:  filter.UpdatedAfter,
+				UpdatedBefore: filter.UpdatedBefore,
+			}
+			if filter.Ref != "" {
+				options.Ref = gitlab.String(filter.Ref)
+			}
+			page, resp, err := client.Pipelines.ListProjectPipelines(project.ID, options)
+			if err != nil {
+				return nil, err
+			}
+			pipelines = append(pipelines, page...)
+			return resp, nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list %s pipelines: %v", status, err)
+		}
+	}
+
+	if len(pipelines) == 0 {
+		fmt.Printf("No matching pipelines found for project %s\n", project.PathWithNamespace)
+	}
+	return pipelines, nil
+}
+
+// RetryOrCancelPipeline retries the failed jobs of the pipeline or cancels it and adds
+// a row to the report. In dry-run mode the pipeline is only reported. A failed action
+// is reported and returned as an error.
+func RetryOrCancelPipeline(client *gitlab.Client, project *gitlab.Project, pipeline *gitlab.PipelineInfo, operation string, dryRun bool, report *Report) error {
+	var err error
+	switch {
+	case dryRun:
+	case operation == RetryPipelines:
+		_, _, err = client.Pipelines.RetryPipelineBuild(project.ID, pipeline.ID)
+	case operation == CancelPipelines:
+		_, _, err = client.Pipelines.CancelPipelineBuild(project.ID, pipeline.ID)
+	default:
+		return fmt.Errorf("unknown pipeline operation: %s", operation)
+	}
+
+	result := "ok"
+	if dryRun {
+		result = "dry run"
+	} else if err != nil {
+		result = err.Error()
+	}
+	report.AddRow(project.PathWithNamespace, strconv.Itoa(pipeline.ID), pipeline.Ref, pipeline.Status, operation, result)
+	if err != nil {
+		return fmt.Errorf("failed to %s pipeline %d: %v", operation, pipeline.ID, err)
+	}
+	return nil
+}
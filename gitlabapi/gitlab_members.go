@@ -0,0 +1,308 @@
+package gitlabapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
+
+	"github.com/xanzy/go-gitlab"
+)
+
+// Operations on project members.
+const (
+	MembersAdd    = "add"    // Add users or share the project with groups
+	MembersRemove = "remove" // Remove users or stop sharing with groups
+	MembersUpdate = "update" // Change the access level of users or shared groups
+)
+
+// MemberChange describes a membership change applied to every project.
+type MemberChange struct {
+	Operation   string
+	UserIDs     []int
+	GroupIDs    []int
+	AccessLevel gitlab.AccessLevelValue
+	ExpiresAt   string // YYYY-MM-DD, optional
+}
+
+// NewMembersReport returns an empty report with the membership change columns.
+func NewMembersReport() *Report {
+	return &Report{Headers: []string{"Project", "Member", "Change", "Result"}}
+}
+
+// ManageMembers applies the membership change to the project and reports the result
+// per user and group. In dry-run mode changes are only reported.
+func ManageMembers(client *gitlab.Client, project *gitlab.Project, change MemberChange, dryRun bool, report *Report) error {
+	var expiresAt *string
+	if change.ExpiresAt != "" {
+		expiresAt = gitlab.String(change.ExpiresAt)
+	}
+
+	apply := func(member, description string, f func() error) {
+		result := "ok"
+		if dryRun {
+			result = "dry run"
+		} else if err := f(); err != nil {
+			result = err.Error()
+		}
+		report.AddRow(project.PathWithNamespace, member, description, result)
+	}
+
+	for _, userID := range change.UserIDs {
+		userID := userID
+		member := "user:" + strconv.Itoa(userID)
+		switch change.Operation {
+		case MembersAdd:
+			apply(member, "add as "+accessLevelName(change.AccessLevel), func() error {
+				_, _, err := client.ProjectMembers.AddProjectMember(project.ID, &gitlab.AddProjectMemberOptions{
+					UserID:      userID,
+					AccessLevel: gitlab.AccessLevel(change.AccessLevel),
+					ExpiresAt:   expiresAt,
+				})
+				return err
+			})
+		case MembersUpdate:
+			apply(member, "change to "+accessLevelName(change.AccessLevel), func() error {
+				_, _, err := client.ProjectMembers.EditProjectMember(project.ID, userID, &gitlab.EditProjectMemberOptions{
+					AccessLevel: gitlab.AccessLevel(change.AccessLevel),
+					ExpiresAt:   expiresAt,
+				})
+				return err
+			})
+		case MembersRemove:
+			apply(member, "remove", func() error {
+				_, err := client.ProjectMembers.DeleteProjectMember(project.ID, userID)
+				return err
+			})
+		default:
+			return fmt.Errorf("unknown members operation: %s", change.Operation)
+		}
+	}
+
+	for _, groupID := range change.GroupIDs {
+		groupID := groupID
+		member := "group:" + strconv.Itoa(groupID)
+		share := func() error {
+			_, err := client.Projects.ShareProjectWithGroup(project.ID, &gitlab.ShareWithGroupOptions{
+				GroupID:     gitlab.Int(groupID),
+				GroupAccess: gitlab.AccessLevel(change.AccessLevel),
+				ExpiresAt:   expiresAt,
+			})
+			return err
+		}
+		unshare := func() error {
+			_, err := client.Projects.DeleteSharedProjectFromGroup(project.ID, groupID)
+			return err
+		}
+
+		switch change.Operation {
+		case MembersAdd:
+			apply(member, "share as "+accessLevelName(change.AccessLevel), share)
+		case MembersUpdate:
+			// Shared group access cannot be edited, so the share is recreated and the
+			// previous access is restored if the new share is rejected
+			apply(member, "change to "+accessLevelName(change.AccessLevel), func() error {
+				previous, err := sharedGroupAccess(client, project.ID, groupID)
+				if err != nil {
+					return err
+				}
+				if err := unshare(); err != nil {
+					return err
+				}
+				if err := share(); err != nil {
+					_, restoreErr := client.Projects.ShareProjectWithGroup(project.ID, &gitlab.ShareWithGroupOptions{
+						GroupID:     gitlab.Int(groupID),
+						GroupAccess: gitlab.AccessLevel(previous),
+					})
+					if restoreErr != nil {
+						return fmt.Errorf("%v; failed to restore %s access: %v", err, accessLevelName(previous), restoreErr)
+					}
+					return fmt.Errorf("%v; restored %s access", err, accessLevelName(previous))
+				}
+				return nil
+			})
+		case MembersRemove:
+			apply(member, "unshare", unshare)
+		default:
+			return fmt.Errorf("unknown members operation: %s", change.Operation)
+		}
+	}
+	return nil
+}
+
+// sharedGroupAccess returns the access level the group currently has on the project.
+func sharedGroupAccess(client *gitlab.Client, projectID, groupID int) (gitlab.AccessLevelValue, error) {
+	project, _, err := client.Projects.GetProject(projectID, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get project %d: %v", projectID, err)
+	}
+	for _, group := range project.SharedWithGroups {
+		if group.GroupID == groupID {
+			return gitlab.AccessLevelValue(group.GroupAccessLevel), nil
+		}
+	}
+	return 0, fmt.Errorf("project is not shared with group %d", groupID)
+}
+
+// AccessPolicy lists who may hold Maintainer or Owner access as a direct project member.
+type AccessPolicy struct {
+	AllowedMaintainers        []string            `json:"allowed_maintainers"`         // Usernames allowed on every project
+	ProjectAllowedMaintainers map[string][]string `json:"project_allowed_maintainers"` // Project path to extra usernames
+}
+
+// LoadAccessPolicy reads an access policy from a JSON file.
+func LoadAccessPolicy(path string) (*AccessPolicy, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	policy := &AccessPolicy{}
+	if err := json.Unmarshal(content, policy); err != nil {
+		return nil, fmt.Errorf("failed to parse access policy %s: %v", path, err)
+	}
+	return policy, nil
+}
+
+// AccessReviewer lists the direct members of projects and flags questionable access.
+// It caches the group members and user details across projects.
+type AccessReviewer struct {
+	client       *gitlab.Client
+	policy       *AccessPolicy
+	groupMembers map[int]gitlab.AccessLevelValue
+	users        map[int]*gitlab.User
+}
+
+// NewAccessReviewer loads the members of the group, including inherited ones, so that
+// redundant direct project memberships can be flagged. policy may be nil.
+func NewAccessReviewer(client *gitlab.Client, groupID int, policy *AccessPolicy) (*AccessReviewer, error) {
+	r := &AccessReviewer{
+		client:       client,
+		policy:       policy,
+		groupMembers: map[int]gitlab.AccessLevelValue{},
+		users:        map[int]*gitlab.User{},
+	}
+
+	err := Paginate(client, groupID, func(options gitlab.ListOptions) (*gitlab.Response, error) {
+		members, resp, err := client.Groups.ListAllGroupMembers(groupID, &gitlab.ListGroupMembersOptions{
+			ListOptions: options,
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, member := range members {
+			r.groupMembers[member.ID] = member.AccessLevel
+		}
+		return resp, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list group members: %v", err)
+	}
+	return r, nil
+}
+
+// NewAccessReviewReport returns an empty report with the access review columns.
+func NewAccessReviewReport() *Report {
+	return &Report{Headers: []string{"Project", "Username", "Access Level", "Expires", "Last Activity", "Flags"}}
+}
+
+// Review adds every direct member of the project to the report without changing anything.
+func (r *AccessReviewer) Review(project *gitlab.Project, report *Report) error {
+	var members []*gitlab.ProjectMember
+	err := Paginate(r.client, project.ID, func(options gitlab.ListOptions) (*gitlab.Response, error) {
+		page, resp, err := r.client.ProjectMembers.ListProjectMembers(project.ID, &gitlab.ListProjectMembersOptions{
+			ListOptions: options,
+		})
+		if err != nil {
+			return nil, err
+		}
+		members = append(members, page...)
+		return resp, nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list members of project %s: %v", project.PathWithNamespace, err)
+	}
+
+	for _, member := range members {
+		var flags []string
+		if level, ok := r.groupMembers[member.ID]; ok && level >= member.AccessLevel {
+			flags = append(flags, "inherits "+accessLevelName(level)+" from group")
+		}
+		if member.AccessLevel >= gitlab.MaintainerPermissions && !r.maintainerAllowed(project, member.Username) {
+			flags = append(flags, "maintainer not allowed by policy")
+		}
+
+		expires := ""
+		if member.ExpiresAt != nil {
+			expires = member.ExpiresAt.String()
+		}
+
+		activity, err := r.lastActivity(member.ID)
+		if err != nil {
+			flags = append(flags, err.Error())
+		}
+
+		report.AddRow(
+			project.PathWithNamespace,
+			member.Username,
+			accessLevelName(member.AccessLevel),
+			expires,
+			activity,
+			strings.Join(flags, "; "),
+		)
+	}
+	return nil
+}
+
+// maintainerAllowed reports whether the policy allows the user Maintainer or Owner
+// access on the project. Everyone is allowed when there is no policy.
+func (r *AccessReviewer) maintainerAllowed(project *gitlab.Project, username string) bool {
+	if r.policy == nil {
+		return true
+	}
+	allowed := append([]string{}, r.policy.AllowedMaintainers...)
+	allowed = append(allowed, r.policy.ProjectAllowedMaintainers[project.PathWithNamespace]...)
+	return containsString(allowed, username)
+}
+
+// lastActivity returns the last activity date of the user. It is only visible to
+// administrators and left empty otherwise. Failed lookups are not cached.
+func (r *AccessReviewer) lastActivity(userID int) (string, error) {
+	user, ok := r.users[userID]
+	if !ok {
+		var err error
+		user, _, err = r.client.Users.GetUser(userID, gitlab.GetUsersOptions{})
+		if err != nil {
+			return "", fmt.Errorf("failed to get user %d: %v", userID, err)
+		}
+		r.users[userID] = user
+	}
+	if user.LastActivityOn == nil {
+		return "", nil
+	}
+	return user.LastActivityOn.String(), nil
+}
+
+// accessLevelName returns the name of the access level as used by ParseAccessLevel.
+func accessLevelName(level gitlab.AccessLevelValue) string {
+	switch level {
+	case gitlab.NoPermissions:
+		return "no"
+	case gitlab.MinimalAccessPermissions:
+		return "minimal"
+	case gitlab.GuestPermissions:
+		return "guest"
+	case gitlab.ReporterPermissions:
+		return "reporter"
+	case gitlab.DeveloperPermissions:
+		return "developer"
+	case gitlab.MaintainerPermissions:
+		return "maintainer"
+	case gitlab.OwnerPermissions:
+		return "owner"
+	case gitlab.AdminPermissions:
+		return "admin"
+	}
+	return strconv.Itoa(int(level))
+}
@@ -0,0 +1,444 @@
+package gitlabapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Modes that decide how a managed file is combined with the file in the repository.
+const (
+	ModeOverwrite = "overwrite" // Replace the whole file
+	ModeBlock     = "block"     // Replace only the managed block between the markers
+	ModeLines     = "lines"     // Add missing lines, keeping existing ones (ignore files)
+	ModeYAML      = "yaml"      // Deep-merge the template into the YAML document
+	ModeJSON      = "json"      // Apply the template as a JSON merge patch (RFC 7386)
+)
+
+// Markers around the block managed in ModeBlock.
+const (
+	managedBlockBegin = "# BEGIN gitlab-go-util"
+	managedBlockEnd   = "# END gitlab-go-util"
+)
+
+// mergeManagedFile combines the rendered template with the current file content
+// according to mode. current is nil when the file does not exist yet.
+func mergeManagedFile(mode string, current, content []byte) ([]byte, error) {
+	switch mode {
+	case "", ModeOverwrite:
+		return content, nil
+	case ModeBlock:
+		return mergeManagedBlock(current, content)
+	case ModeLines:
+		return mergeLineSet(current, content), nil
+	case ModeYAML:
+		return mergeYAML(current, content)
+	case ModeJSON:
+		return mergeJSONPatch(current, content)
+	}
+	return nil, fmt.Errorf("unknown merge mode: %s", mode)
+}
+
+// mergeManagedBlock replaces the content between the managed block markers, or appends
+// the block if the file has none. Lines outside the block are kept untouched. Markers
+// only count as whole lines. A marker without its pair, or more than one block, is an
+// error rather than a second block.
+func mergeManagedBlock(current, content []byte) ([]byte, error) {
+	block := managedBlockBegin + "\n" + strings.TrimRight(string(content), "\n") + "\n" + managedBlockEnd + "\n"
+
+	text := string(current)
+	lines := strings.SplitAfter(text, "\n")
+	begin, end := -1, -1
+	for i, line := range lines {
+		switch strings.TrimSpace(line) {
+		case managedBlockBegin:
+			if begin >= 0 {
+				return nil, fmt.Errorf("file contains more than one managed block")
+			}
+			begin = i
+		case managedBlockEnd:
+			if end >= 0 {
+				return nil, fmt.Errorf("file contains more than one managed block")
+			}
+			end = i
+		}
+	}
+	switch {
+	case (begin >= 0) != (end >= 0):
+		return nil, fmt.Errorf("file contains an unpaired managed block marker")
+	case end < begin:
+		return nil, fmt.Errorf("managed block end marker comes before the begin marker")
+	case begin >= 0:
+		return []byte(strings.Join(lines[:begin], "") + block + strings.Join(lines[end+1:], "")), nil
+	}
+
+	if text == "" {
+		return []byte(block), nil
+	}
+	if !strings.HasSuffix(text, "\n") {
+		text += "\n"
+	}
+	return []byte(text + "\n" + block), nil
+}
+
+// mergeLineSet appends every non-empty template line that is not yet in the file.
+func mergeLineSet(current, content []byte) []byte {
+	existing := map[string]bool{}
+	for _, line := range strings.Split(string(current), "\n") {
+		existing[strings.TrimSpace(line)] = true
+	}
+
+	text := string(current)
+	var missing []string
+	for _, line := range strings.Split(string(content), "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || existing[trimmed] {
+			continue
+		}
+		existing[trimmed] = true
+		missing = append(missing, trimmed)
+	}
+	if len(missing) == 0 {
+		return current
+	}
+
+	if text != "" && !strings.HasSuffix(text, "\n") {
+		text += "\n"
+	}
+	return []byte(text + strings.Join(missing, "\n") + "\n")
+}
+
+// mergeYAML deep-merges the template document into the current one. Mappings are merged
+// key by key; any other value in the template replaces the current value. The merge is
+// done on the YAML nodes, so comments and key order of the current document are kept,
+// and an unchanged document is returned as it is.
+func mergeYAML(current, content []byte) ([]byte, error) {
+	var base, overlay yaml.Node
+	if err := yaml.Unmarshal(current, &base); err != nil {
+		return nil, fmt.Errorf("failed to parse current YAML: %v", err)
+	}
+	if err := yaml.Unmarshal(content, &overlay); err != nil {
+		return nil, fmt.Errorf("failed to parse YAML template: %v", err)
+	}
+	if len(base.Content) == 0 {
+		return content, nil
+	}
+	if len(overlay.Content) == 0 || !mergeYAMLNode(base.Content[0], overlay.Content[0]) {
+		return current, nil
+	}
+
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(yamlIndent(current))
+	if err := encoder.Encode(&base); err != nil {
+		return nil, err
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// mergeYAMLNode merges overlay into base, recursing into mappings, and reports whether
+// base changed. Values that are already equal are left alone with their comments; null
+// values in the template keep the current value.
+func mergeYAMLNode(base, overlay *yaml.Node) bool {
+	if overlay.Tag == "!!null" {
+		return false
+	}
+	if base.Kind != yaml.MappingNode || overlay.Kind != yaml.MappingNode {
+		if yamlEqual(base, overlay) {
+			return false
+		}
+		headComment, lineComment, footComment := base.HeadComment, base.LineComment, base.FootComment
+		*base = *overlay
+		if base.HeadComment == "" {
+			base.HeadComment = headComment
+		}
+		if base.LineComment == "" {
+			base.LineComment = lineComment
+		}
+		if base.FootComment == "" {
+			base.FootComment = footComment
+		}
+		return true
+	}
+
+	changed := false
+	for i := 0; i+1 < len(overlay.Content); i += 2 {
+		key, value := overlay.Content[i], overlay.Content[i+1]
+		found := false
+		for j := 0; j+1 < len(base.Content); j += 2 {
+			if base.Content[j].Value == key.Value {
+				found = true
+				if mergeYAMLNode(base.Content[j+1], value) {
+					changed = true
+				}
+				break
+			}
+		}
+		if !found && value.Tag != "!!null" {
+			base.Content = append(base.Content, key, value)
+			changed = true
+		}
+	}
+	return changed
+}
+
+// yamlEqual reports whether both nodes hold the same value, ignoring style and comments.
+func yamlEqual(a, b *yaml.Node) bool {
+	var aValue, bValue interface{}
+	if err := a.Decode(&aValue); err != nil {
+		return false
+	}
+	if err := b.Decode(&bValue); err != nil {
+		return false
+	}
+	return reflect.DeepEqual(aValue, bValue)
+}
+
+// yamlIndent returns the smallest indentation of the document, defaulting to 2.
+func yamlIndent(content []byte) int {
+	indent := 0
+	for _, line := range strings.Split(string(content), "\n") {
+		trimmed := strings.TrimLeft(line, " ")
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		if n := len(line) - len(trimmed); n > 0 && (indent == 0 || n < indent) {
+			indent = n
+		}
+	}
+	if indent < 2 {
+		return 2
+	}
+	return indent
+}
+
+// mergeJSONPatch applies the template as a JSON merge patch to the current document.
+// The order of the existing keys is kept, new keys are appended, HTML characters are
+// not escaped and an unchanged document is returned as it is.
+func mergeJSONPatch(current, content []byte) ([]byte, error) {
+	patch, err := parseOrderedJSON(content)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse JSON template: %v", err)
+	}
+
+	var target interface{}
+	if len(bytes.TrimSpace(current)) > 0 {
+		target, err = parseOrderedJSON(current)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse current JSON: %v", err)
+		}
+	}
+
+	merged, changed := mergePatch(target, patch)
+	if target != nil && !changed {
+		return current, nil
+	}
+
+	var buf bytes.Buffer
+	if err := writeOrderedJSON(&buf, merged, jsonIndent(current), ""); err != nil {
+		return nil, err
+	}
+	buf.WriteByte('\n')
+	return buf.Bytes(), nil
+}
+
+// mergePatch implements the RFC 7386 merge algorithm: null removes a member, objects are
+// merged recursively and every other value replaces the target. It reports whether the
+// target changed.
+func mergePatch(target, patch interface{}) (interface{}, bool) {
+	patchObject, ok := patch.(*jsonObject)
+	if !ok {
+		return patch, !jsonEqual(target, patch)
+	}
+
+	changed := false
+	targetObject, ok := target.(*jsonObject)
+	if !ok {
+		targetObject = newJSONObject()
+		changed = true
+	}
+	for _, key := range patchObject.keys {
+		value := patchObject.values[key]
+		if value == nil {
+			if _, exists := targetObject.values[key]; exists {
+				targetObject.remove(key)
+				changed = true
+			}
+			continue
+		}
+		merged, valueChanged := mergePatch(targetObject.values[key], value)
+		targetObject.set(key, merged)
+		changed = changed || valueChanged
+	}
+	return targetObject, changed
+}
+
+// jsonObject is a decoded JSON object that keeps the order of its keys.
+type jsonObject struct {
+	keys   []string
+	values map[string]interface{}
+}
+
+// newJSONObject returns an empty object.
+func newJSONObject() *jsonObject {
+	return &jsonObject{values: map[string]interface{}{}}
+}
+
+// set adds the key at the end, or replaces its value in place if it exists.
+func (o *jsonObject) set(key string, value interface{}) {
+	if _, ok := o.values[key]; !ok {
+		o.keys = append(o.keys, key)
+	}
+	o.values[key] = value
+}
+
+// remove deletes the key.
+func (o *jsonObject) remove(key string) {
+	delete(o.values, key)
+	for i, k := range o.keys {
+		if k == key {
+			o.keys = append(o.keys[:i], o.keys[i+1:]...)
+			return
+		}
+	}
+}
+
+// parseOrderedJSON decodes a JSON document into *jsonObject, []interface{} and scalar
+// values. Numbers are kept as json.Number so that they are written back unchanged.
+func parseOrderedJSON(data []byte) (interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	value, err := decodeOrderedJSON(decoder)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := decoder.Token(); err != io.EOF {
+		return nil, fmt.Errorf("unexpected data after the JSON document")
+	}
+	return value, nil
+}
+
+// decodeOrderedJSON decodes the next value from the decoder.
+func decodeOrderedJSON(decoder *json.Decoder) (interface{}, error) {
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	switch token {
+	case json.Delim('{'):
+		object := newJSONObject()
+		for decoder.More() {
+			key, err := decoder.Token()
+			if err != nil {
+				return nil, err
+			}
+			value, err := decodeOrderedJSON(decoder)
+			if err != nil {
+				return nil, err
+			}
+			object.set(key.(string), value)
+		}
+		_, err := decoder.Token()
+		return object, err
+	case json.Delim('['):
+		array := []interface{}{}
+		for decoder.More() {
+			value, err := decodeOrderedJSON(decoder)
+			if err != nil {
+				return nil, err
+			}
+			array = append(array, value)
+		}
+		_, err := decoder.Token()
+		return array, err
+	}
+	return token, nil
+}
+
+// writeOrderedJSON writes the value as indented JSON. Objects keep their key order.
+func writeOrderedJSON(buf *bytes.Buffer, value interface{}, indent, prefix string) error {
+	switch v := value.(type) {
+	case *jsonObject:
+		if len(v.keys) == 0 {
+			buf.WriteString("{}")
+			return nil
+		}
+		buf.WriteString("{\n")
+		for i, key := range v.keys {
+			buf.WriteString(prefix + indent)
+			if err := writeJSONScalar(buf, key); err != nil {
+				return err
+			}
+			buf.WriteString(": ")
+			if err := writeOrderedJSON(buf, v.values[key], indent, prefix+indent); err != nil {
+				return err
+			}
+			if i < len(v.keys)-1 {
+				buf.WriteByte(',')
+			}
+			buf.WriteByte('\n')
+		}
+		buf.WriteString(prefix + "}")
+	case []interface{}:
+		if len(v) == 0 {
+			buf.WriteString("[]")
+			return nil
+		}
+		buf.WriteString("[\n")
+		for i, element := range v {
+			buf.WriteString(prefix + indent)
+			if err := writeOrderedJSON(buf, element, indent, prefix+indent); err != nil {
+				return err
+			}
+			if i < len(v)-1 {
+				buf.WriteByte(',')
+			}
+			buf.WriteByte('\n')
+		}
+		buf.WriteString(prefix + "]")
+	default:
+		return writeJSONScalar(buf, v)
+	}
+	return nil
+}
+
+// writeJSONScalar writes a string, number, boolean or null without escaping HTML.
+func writeJSONScalar(buf *bytes.Buffer, value interface{}) error {
+	encoder := json.NewEncoder(buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(value); err != nil {
+		return err
+	}
+	// Encode ends the value with a newline
+	buf.Truncate(buf.Len() - 1)
+	return nil
+}
+
+// jsonEqual reports whether both values encode to the same JSON.
+func jsonEqual(a, b interface{}) bool {
+	var aJSON, bJSON bytes.Buffer
+	if writeOrderedJSON(&aJSON, a, "", "") != nil || writeOrderedJSON(&bJSON, b, "", "") != nil {
+		return false
+	}
+	return bytes.Equal(aJSON.Bytes(), bJSON.Bytes())
+}
+
+// jsonIndent returns the indentation of the first indented line, defaulting to two spaces.
+func jsonIndent(content []byte) string {
+	for _, line := range strings.Split(string(content), "\n") {
+		trimmed := strings.TrimLeft(line, " \t")
+		if trimmed != "" && len(trimmed) < len(line) {
+			return line[:len(line)-len(trimmed)]
+		}
+	}
+	return "  "
+}
@@ -0,0 +1,173 @@
+package gitlabapi
+
+import "testing"
+
+func TestMergeManagedBlock(t *testing.T) {
+	tests := []struct {
+		name    string
+		current string
+		content string
+		want    string
+		wantErr bool
+	}{
+		{
+			name:    "empty file",
+			current: "",
+			content: "managed",
+			want:    "# BEGIN gitlab-go-util\nmanaged\n# END gitlab-go-util\n",
+		},
+		{
+			name:    "append block",
+			current: "own",
+			content: "managed\n",
+			want:    "own\n\n# BEGIN gitlab-go-util\nmanaged\n# END gitlab-go-util\n",
+		},
+		{
+			name:    "replace block",
+			current: "before\n# BEGIN gitlab-go-util\nold\n# END gitlab-go-util\nafter\n",
+			content: "new",
+			want:    "before\n# BEGIN gitlab-go-util\nnew\n# END gitlab-go-util\nafter\n",
+		},
+		{
+			name:    "markers only match whole lines",
+			current: "# BEGIN gitlab-go-util-extra\nsee # END gitlab-go-util docs\n",
+			content: "new",
+			want:    "# BEGIN gitlab-go-util-extra\nsee # END gitlab-go-util docs\n\n# BEGIN gitlab-go-util\nnew\n# END gitlab-go-util\n",
+		},
+		{
+			name:    "begin without end",
+			current: "# BEGIN gitlab-go-util\nold\n",
+			content: "new",
+			wantErr: true,
+		},
+		{
+			name:    "end before begin",
+			current: "# END gitlab-go-util\n# BEGIN gitlab-go-util\n",
+			content: "new",
+			wantErr: true,
+		},
+		{
+			name:    "two blocks",
+			current: "# BEGIN gitlab-go-util\n# END gitlab-go-util\n# BEGIN gitlab-go-util\n# END gitlab-go-util\n",
+			content: "new",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := mergeManagedBlock([]byte(tt.current), []byte(tt.content))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("mergeManagedBlock() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if string(got) != tt.want {
+				t.Errorf("mergeManagedBlock() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMergeYAML(t *testing.T) {
+	tests := []struct {
+		name    string
+		current string
+		content string
+		want    string
+	}{
+		{
+			name:    "empty file takes the template",
+			current: "",
+			content: "a: 1 # one\n",
+			want:    "a: 1 # one\n",
+		},
+		{
+			name:    "unchanged document is kept as it is",
+			current: "# top\nb:   2\na: 1\n",
+			content: "a: 1\n",
+			want:    "# top\nb:   2\na: 1\n",
+		},
+		{
+			name:    "merge keeps comments and order",
+			current: "# top\nvariables:\n  A: \"1\" # keep\n  B: x\nstages:\n  - build\n",
+			content: "variables:\n  B: y\n  C: z\n",
+			want:    "# top\nvariables:\n  A: \"1\" # keep\n  B: y\n  C: z\nstages:\n  - build\n",
+		},
+		{
+			name:    "null keeps the current value",
+			current: "a: 1\n",
+			content: "a: null\nb: 2\n",
+			want:    "a: 1\nb: 2\n",
+		},
+		{
+			name:    "indentation is kept",
+			current: "a:\n    b: 1\n",
+			content: "a:\n  c: 2\n",
+			want:    "a:\n    b: 1\n    c: 2\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := mergeYAML([]byte(tt.current), []byte(tt.content))
+			if err != nil {
+				t.Fatalf("mergeYAML() error = %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("mergeYAML() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMergeJSONPatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		current string
+		content string
+		want    string
+		wantErr bool
+	}{
+		{
+			name:    "empty file takes the patch",
+			current: "",
+			content: `{"b": 1, "a": "<x>"}`,
+			want:    "{\n  \"b\": 1,\n  \"a\": \"<x>\"\n}\n",
+		},
+		{
+			name:    "unchanged document is kept as it is",
+			current: `{"z": 1, "a": 2}`,
+			content: `{"a": 2}`,
+			want:    `{"z": 1, "a": 2}`,
+		},
+		{
+			name:    "key order and indentation are kept",
+			current: "{\n\t\"z\": 1,\n\t\"a\": {\"x\": \"&\", \"y\": 2.50}\n}\n",
+			content: `{"a": {"y": null, "w": true}, "b": [1, 2]}`,
+			want:    "{\n\t\"z\": 1,\n\t\"a\": {\n\t\t\"x\": \"&\",\n\t\t\"w\": true\n\t},\n\t\"b\": [\n\t\t1,\n\t\t2\n\t]\n}\n",
+		},
+		{
+			name:    "non-object patch replaces the document",
+			current: `{"a": 1}`,
+			content: `[1]`,
+			want:    "[\n  1\n]\n",
+		},
+		{
+			name:    "invalid current document",
+			current: `{"a": `,
+			content: `{}`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := mergeJSONPatch([]byte(tt.current), []byte(tt.content))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("mergeJSONPatch() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if string(got) != tt.want {
+				t.Errorf("mergeJSONPatch() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,212 @@
+package gitlabapi
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/xanzy/go-gitlab"
+)
+
+// NewPipelineHealthReport returns an empty report with the pipeline health columns.
+func NewPipelineHealthReport() *Report {
+	return &Report{Headers: []string{"Project", "Ref", "Status", "Failing Jobs", "Red Since", "Last Success", "Flaky Jobs"}}
+}
+
+// PipelineHealth adds the health of the latest pipelines on ref to the report. The ref
+// defaults to the project's default branch. Jobs that were retried and passed on the
+// same commit within the last depth pipelines are reported as flaky. Nothing is modified.
+func PipelineHealth(client *gitlab.Client, project *gitlab.Project, ref string, depth int, report *Report) error {
+	if ref == "" {
+		ref = project.DefaultBranch
+	}
+
+	var pipelines []*gitlab.PipelineInfo
+	err := Paginate(client, project.ID, func(options gitlab.ListOptions) (*gitlab.Response, error) {
+		page, resp, err := client.Pipelines.ListProjectPipelines(project.ID, &gitlab.ListProjectPipelinesOptions{
+			ListOptions: options,
+			Ref:         gitlab.String(ref),
+			OrderBy:     gitlab.String("id"),
+			Sort:        gitlab.String("desc"),
+		})
+		if err != nil {
+			return nil, err
+		}
+		pipelines = append(pipelines, page...)
+		if len(pipelines) >= depth {
+			// Stop paginating once enough pipelines are collected
+			pipelines = pipelines[:depth]
+			resp.NextPage = 0
+		}
+		return resp, nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list pipelines: %v", err)
+	}
+
+	if len(pipelines) == 0 {
+		report.AddRow(project.PathWithNamespace, ref, "none", "", "", "", "")
+		return nil
+	}
+
+	latest := pipelines[0]
+	var failingJobs []string
+	if latest.Status == "failed" {
+		failingJobs, err = listFailedJobs(client, project.ID, latest.ID)
+		if err != nil {
+			return err
+		}
+	}
+
+	// The last success is looked up on its own as it can be older than the depth window
+	lastSuccess, err := lastSuccessfulPipeline(client, project.ID, ref)
+	if err != nil {
+		return err
+	}
+	lastSuccessID := 0
+	lastSuccessAt := ""
+	if lastSuccess != nil {
+		lastSuccessID = lastSuccess.ID
+		lastSuccessAt = formatTime(lastSuccess.UpdatedAt)
+	}
+
+	// A failing ref has been red since the first pipeline after the last success
+	redSince := ""
+	if latest.Status == "failed" {
+		first, err := firstPipelineAfter(client, project.ID, ref, lastSuccessID)
+		if err != nil {
+			return err
+		}
+		if first != nil {
+			redSince = formatTime(first.CreatedAt)
+		}
+	}
+
+	flakyJobs, err := findFlakyJobs(client, project.ID, pipelines)
+	if err != nil {
+		return err
+	}
+
+	report.AddRow(
+		project.PathWithNamespace,
+		ref,
+		latest.Status,
+		strings.Join(failingJobs, ", "),
+		redSince,
+		lastSuccessAt,
+		strings.Join(flakyJobs, ", "),
+	)
+	return nil
+}
+
+// lastSuccessfulPipeline returns the latest successful pipeline on ref, or nil if there
+// is none.
+func lastSuccessfulPipeline(client *gitlab.Client, projectID int, ref string) (*gitlab.PipelineInfo, error) {
+	pipelines, _, err := client.Pipelines.ListProjectPipelines(projectID, &gitlab.ListProjectPipelinesOptions{
+		ListOptions: gitlab.ListOptions{PerPage: 1},
+		Ref:         gitlab.String(ref),
+		Status:      gitlab.BuildState(gitlab.Success),
+		OrderBy:     gitlab.String("id"),
+		Sort:        gitlab.String("desc"),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get last successful pipeline: %v", err)
+	}
+	if len(pipelines) == 0 {
+		return nil, nil
+	}
+	return pipelines[0], nil
+}
+
+// firstPipelineAfter returns the oldest pipeline on ref whose ID is greater than
+// afterID, or nil if there is none. Pipelines are listed newest first until afterID is
+// reached.
+func firstPipelineAfter(client *gitlab.Client, projectID int, ref string, afterID int) (*gitlab.PipelineInfo, error) {
+	var first *gitlab.PipelineInfo
+	err := Paginate(client, projectID, func(options gitlab.ListOptions) (*gitlab.Response, error) {
+		page, resp, err := client.Pipelines.ListProjectPipelines(projectID, &gitlab.ListProjectPipelinesOptions{
+			ListOptions: options,
+			Ref:         gitlab.String(ref),
+			OrderBy:     gitlab.String("id"),
+			Sort:        gitlab.String("desc"),
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, pipeline := range page {
+			if pipeline.ID <= afterID {
+				resp.NextPage = 0
+				break
+			}
+			first = pipeline
+		}
+		return resp, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pipelines: %v", err)
+	}
+	return first, nil
+}
+
+// findFlakyJobs lists the jobs of the pipelines, including retried ones, and returns
+// the names of the flaky jobs.
+func findFlakyJobs(client *gitlab.Client, projectID int, pipelines []*gitlab.PipelineInfo) ([]string, error) {
+	jobsBySHA := map[string][]*gitlab.Job{}
+	for _, pipeline := range pipelines {
+		err := Paginate(client, projectID, func(options gitlab.ListOptions) (*gitlab.Response, error) {
+			jobs, resp, err := client.Jobs.ListPipelineJobs(projectID, pipeline.ID, &gitlab.ListJobsOptions{
+				ListOptions:    options,
+				IncludeRetried: gitlab.Bool(true),
+			})
+			if err != nil {
+				return nil, err
+			}
+			jobsBySHA[pipeline.SHA] = append(jobsBySHA[pipeline.SHA], jobs...)
+			return resp, nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list jobs of pipeline %d: %v", pipeline.ID, err)
+		}
+	}
+	return flakyJobNames(jobsBySHA), nil
+}
+
+// flakyJobNames returns the names of jobs that both failed and succeeded on the same
+// commit, i.e. that passed on a retry without a code change. A job that failed on one
+// commit and passed on a later one was fixed, not flaky.
+func flakyJobNames(jobsBySHA map[string][]*gitlab.Job) []string {
+	flaky := map[string]bool{}
+	for _, jobs := range jobsBySHA {
+		failed := map[string]bool{}
+		succeeded := map[string]bool{}
+		for _, job := range jobs {
+			switch job.Status {
+			case "failed":
+				failed[job.Name] = true
+			case "success":
+				succeeded[job.Name] = true
+			}
+		}
+		for name := range failed {
+			if succeeded[name] {
+				flaky[name] = true
+			}
+		}
+	}
+
+	var names []string
+	for name := range flaky {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// formatTime formats a timestamp for reports, or returns an empty string if it is unset.
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
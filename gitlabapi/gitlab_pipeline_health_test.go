@@ -0,0 +1,57 @@
+package gitlabapi
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/xanzy/go-gitlab"
+)
+
+func TestFlakyJobNames(t *testing.T) {
+	job := func(name, status string) *gitlab.Job {
+		return &gitlab.Job{Name: name, Status: status}
+	}
+
+	tests := []struct {
+		name      string
+		jobsBySHA map[string][]*gitlab.Job
+		want      []string
+	}{
+		{
+			name:      "no jobs",
+			jobsBySHA: map[string][]*gitlab.Job{},
+			want:      nil,
+		},
+		{
+			name: "retried job passed on the same commit",
+			jobsBySHA: map[string][]*gitlab.Job{
+				"a": {job("test", "failed"), job("test", "success"), job("lint", "success")},
+			},
+			want: []string{"test"},
+		},
+		{
+			name: "fixed by a later commit is not flaky",
+			jobsBySHA: map[string][]*gitlab.Job{
+				"a": {job("test", "failed")},
+				"b": {job("test", "success")},
+			},
+			want: nil,
+		},
+		{
+			name: "sorted across commits",
+			jobsBySHA: map[string][]*gitlab.Job{
+				"a": {job("unit", "success"), job("unit", "failed")},
+				"b": {job("e2e", "failed"), job("e2e", "success"), job("build", "canceled")},
+			},
+			want: []string{"e2e", "unit"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := flakyJobNames(tt.jobsBySHA); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("flakyJobNames() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
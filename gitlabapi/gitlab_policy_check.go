@@ -0,0 +1,218 @@
+package gitlabapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/xanzy/go-gitlab"
+)
+
+// ProtectedBranchPolicy is the required protection of a branch in a policy file.
+// Access levels are names accepted by ParseAccessLevel. The Premium-only unprotect
+// access level and code owner approval are not checked when they are left out.
+type ProtectedBranchPolicy struct {
+	Name                      string `json:"name"`
+	PushAccessLevel           string `json:"push_access_level"`
+	MergeAccessLevel          string `json:"merge_access_level"`
+	UnprotectAccessLevel      string `json:"unprotect_access_level"`
+	AllowedUserIDs            []int  `json:"allowed_user_ids"`
+	AllowedGroupIDs           []int  `json:"allowed_group_ids"`
+	AllowForcePush            bool   `json:"allow_force_push"`
+	CodeOwnerApprovalRequired bool   `json:"code_owner_approval_required"`
+
+	protection BranchProtection
+}
+
+// VariablePolicy requires a CI/CD variable to exist. Flags that are left out are not checked.
+type VariablePolicy struct {
+	Key              string                    `json:"key"`
+	EnvironmentScope string                    `json:"environment_scope"`
+	Protected        *bool                     `json:"protected"`
+	Masked           *bool                     `json:"masked"`
+	VariableType     *gitlab.VariableTypeValue `json:"variable_type"`
+}
+
+// Policy describes the settings every project must comply with.
+type Policy struct {
+	ProtectedBranches []*ProtectedBranchPolicy           `json:"protected_branches"`
+	PushRules         *gitlab.EditProjectPushRuleOptions `json:"push_rules"`
+	Settings          *ProjectSettings                   `json:"settings"`
+	RequiredFiles     []string                           `json:"required_files"`
+	Variables         []VariablePolicy                   `json:"ci_variables"`
+}
+
+// ComplianceResult is the outcome of one policy rule for one project.
+type ComplianceResult struct {
+	Project string
+	Rule    string
+	Passed  bool
+	Message string
+}
+
+// LoadPolicy reads a policy from a JSON file.
+func LoadPolicy(path string) (*Policy, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	policy := &Policy{}
+	if err := json.Unmarshal(content, policy); err != nil {
+		return nil, fmt.Errorf("failed to parse policy %s: %v", path, err)
+	}
+
+	for _, branch := range policy.ProtectedBranches {
+		branch.protection = DefaultBranchProtection()
+		branch.protection.UnprotectAccessLevel = gitlab.NoPermissions
+		levels := []struct {
+			name  string
+			level *gitlab.AccessLevelValue
+		}{
+			{branch.PushAccessLevel, &branch.protection.PushAccessLevel},
+			{branch.MergeAccessLevel, &branch.protection.MergeAccessLevel},
+			{branch.UnprotectAccessLevel, &branch.protection.UnprotectAccessLevel},
+		}
+		for _, l := range levels {
+			if l.name == "" {
+				continue
+			}
+			if *l.level, err = ParseAccessLevel(l.name); err != nil {
+				return nil, fmt.Errorf("protected branch %s: %v", branch.Name, err)
+			}
+		}
+		branch.protection.AllowedUserIDs = branch.AllowedUserIDs
+		branch.protection.AllowedGroupIDs = branch.AllowedGroupIDs
+		branch.protection.AllowForcePush = branch.AllowForcePush
+		branch.protection.CodeOwnerApprovalRequired = branch.CodeOwnerApprovalRequired
+	}
+	return policy, nil
+}
+
+// CheckCompliance evaluates every rule of the policy against the project without
+// modifying anything.
+func CheckCompliance(client *gitlab.Client, project *gitlab.Project, policy *Policy) ([]ComplianceResult, error) {
+	var results []ComplianceResult
+	add := func(rule string, passed bool, message string) {
+		results = append(results, ComplianceResult{
+			Project: project.PathWithNamespace,
+			Rule:    rule,
+			Passed:  passed,
+			Message: message,
+		})
+	}
+
+	for _, branch := range policy.ProtectedBranches {
+		rule := "protected-branch:" + branch.Name
+		current, resp, err := client.ProtectedBranches.GetProtectedBranch(project.ID, branch.Name)
+		switch {
+		case err != nil && resp != nil && resp.StatusCode == http.StatusNotFound:
+			add(rule, false, "branch is not protected")
+		case err != nil:
+			return nil, fmt.Errorf("failed to get protection of branch %s: %v", branch.Name, err)
+		case !protectionMatches(current, branch.protection):
+			add(rule, false, "protection differs from policy")
+		default:
+			add(rule, true, "")
+		}
+	}
+
+	if policy.PushRules != nil {
+		current, err := getPushRules(client, project.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get push rules: %v", err)
+		}
+		if current == nil {
+			add("push-rules", false, "project has no push rules")
+		} else {
+			drifts := PushRuleDrift(current, policy.PushRules)
+			add("push-rules", len(drifts) == 0, joinDrifts(drifts))
+		}
+	}
+
+	if policy.Settings != nil {
+		// The listed project may lack some settings, so fetch the full project
+		full, _, err := client.Projects.GetProject(project.ID, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get project: %v", err)
+		}
+		drifts := ProjectSettingsDrift(full, policy.Settings)
+		add("mr-settings", len(drifts) == 0, joinDrifts(drifts))
+	}
+
+	for _, file := range policy.RequiredFiles {
+		_, exists, err := getFileContent(client, project.ID, file, project.DefaultBranch)
+		if err != nil {
+			return nil, err
+		}
+		message := ""
+		if !exists {
+			message = "file is missing on " + project.DefaultBranch
+		}
+		add("file:"+file, exists, message)
+	}
+
+	if len(policy.Variables) > 0 {
+		variables, err := listProjectVariables(client, project.ID)
+		if err != nil {
+			return nil, err
+		}
+		for _, required := range policy.Variables {
+			passed, message := checkVariable(variables, required)
+			add("variable:"+required.Key, passed, message)
+		}
+	}
+
+	return results, nil
+}
+
+// listProjectVariables lists all CI/CD variables of the project.
+func listProjectVariables(client *gitlab.Client, projectID int) ([]*gitlab.ProjectVariable, error) {
+	var variables []*gitlab.ProjectVariable
+	err := Paginate(client, projectID, func(options gitlab.ListOptions) (*gitlab.Response, error) {
+		page, resp, err := client.ProjectVariables.ListVariables(projectID, &gitlab.ListProjectVariablesOptions{
+			Page:    options.Page,
+			PerPage: options.PerPage,
+		})
+		if err != nil {
+			return nil, err
+		}
+		variables = append(variables, page...)
+		return resp, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list variables for project %d: %v", projectID, err)
+	}
+	return variables, nil
+}
+
+// checkVariable reports whether a variable matching the policy exists.
+func checkVariable(variables []*gitlab.ProjectVariable, required VariablePolicy) (bool, string) {
+	scope := required.EnvironmentScope
+	if scope == "" {
+		scope = "*"
+	}
+
+	for _, variable := range variables {
+		if variable.Key != required.Key || variable.EnvironmentScope != scope {
+			continue
+		}
+		var drifts driftList
+		compare(&drifts, "protected", variable.Protected, required.Protected)
+		compare(&drifts, "masked", variable.Masked, required.Masked)
+		compare(&drifts, "variable_type", variable.VariableType, required.VariableType)
+		return len(drifts) == 0, joinDrifts(drifts)
+	}
+	return false, fmt.Sprintf("variable is missing for scope %s", scope)
+}
+
+// joinDrifts formats the drifts as a single line.
+func joinDrifts(drifts []FieldDrift) string {
+	parts := make([]string, 0, len(drifts))
+	for _, drift := range drifts {
+		parts = append(parts, drift.String())
+	}
+	return strings.Join(parts, "; ")
+}
@@ -0,0 +1,94 @@
+package gitlabapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+
+	"github.com/xanzy/go-gitlab"
+)
+
+// ProjectSettings are the desired merge request settings of a project. Fields that are
+// left out of the settings file are not managed.
+type ProjectSettings struct {
+	MergeMethod                               *gitlab.MergeMethodValue  `json:"merge_method"`
+	SquashOption                              *gitlab.SquashOptionValue `json:"squash_option"`
+	OnlyAllowMergeIfPipelineSucceeds          *bool                     `json:"only_allow_merge_if_pipeline_succeeds"`
+	AllowMergeOnSkippedPipeline               *bool                     `json:"allow_merge_on_skipped_pipeline"`
+	OnlyAllowMergeIfAllDiscussionsAreResolved *bool                     `json:"only_allow_merge_if_all_discussions_are_resolved"`
+	ResolveOutdatedDiffDiscussions            *bool                     `json:"resolve_outdated_diff_discussions"`
+	RemoveSourceBranchAfterMerge              *bool                     `json:"remove_source_branch_after_merge"`
+	DefaultBranch                             *string                   `json:"default_branch"`
+	MergeCommitTemplate                       *string                   `json:"merge_commit_template"`
+	SquashCommitTemplate                      *string                   `json:"squash_commit_template"`
+}
+
+// LoadProjectSettings reads the desired project settings from a JSON file.
+func LoadProjectSettings(path string) (*ProjectSettings, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	settings := &ProjectSettings{}
+	if err := json.Unmarshal(content, settings); err != nil {
+		return nil, fmt.Errorf("failed to parse project settings %s: %v", path, err)
+	}
+	return settings, nil
+}
+
+// ReconcileProjectSettings compares the project with the desired settings, reports the
+// drift and applies only the differing fields. In dry-run mode nothing is changed.
+func ReconcileProjectSettings(client *gitlab.Client, projectID int, settings *ProjectSettings, dryRun bool) error {
+	project, _, err := client.Projects.GetProject(projectID, nil)
+	if err != nil {
+		return fmt.Errorf("failed to get project %d: %v", projectID, err)
+	}
+
+	drifts := ProjectSettingsDrift(project, settings)
+	printDrift(project.PathWithNamespace, drifts)
+	if len(drifts) == 0 || dryRun {
+		return nil
+	}
+
+	_, _, err = client.Projects.EditProject(projectID, editOptionsForDrift(project, settings))
+	if err != nil {
+		return fmt.Errorf("failed to update settings for project %s: %v", project.PathWithNamespace, err)
+	}
+
+	log.Printf("Updated %d setting(s) for project %s", len(drifts), project.PathWithNamespace)
+	return nil
+}
+
+// ProjectSettingsDrift returns the managed settings whose current value differs.
+func ProjectSettingsDrift(project *gitlab.Project, settings *ProjectSettings) []FieldDrift {
+	var drifts driftList
+	compare(&drifts, "merge_method", project.MergeMethod, settings.MergeMethod)
+	compare(&drifts, "squash_option", project.SquashOption, settings.SquashOption)
+	compare(&drifts, "only_allow_merge_if_pipeline_succeeds", project.OnlyAllowMergeIfPipelineSucceeds, settings.OnlyAllowMergeIfPipelineSucceeds)
+	compare(&drifts, "allow_merge_on_skipped_pipeline", project.AllowMergeOnSkippedPipeline, settings.AllowMergeOnSkippedPipeline)
+	compare(&drifts, "only_allow_merge_if_all_discussions_are_resolved", project.OnlyAllowMergeIfAllDiscussionsAreResolved, settings.OnlyAllowMergeIfAllDiscussionsAreResolved)
+	compare(&drifts, "resolve_outdated_diff_discussions", project.ResolveOutdatedDiffDiscussions, settings.ResolveOutdatedDiffDiscussions)
+	compare(&drifts, "remove_source_branch_after_merge", project.RemoveSourceBranchAfterMerge, settings.RemoveSourceBranchAfterMerge)
+	compare(&drifts, "default_branch", project.DefaultBranch, settings.DefaultBranch)
+	compare(&drifts, "merge_commit_template", project.MergeCommitTemplate, settings.MergeCommitTemplate)
+	compare(&drifts, "squash_commit_template", project.SquashCommitTemplate, settings.SquashCommitTemplate)
+	return drifts
+}
+
+// editOptionsForDrift builds edit options that only contain the differing settings.
+func editOptionsForDrift(project *gitlab.Project, settings *ProjectSettings) *gitlab.EditProjectOptions {
+	options := &gitlab.EditProjectOptions{}
+	options.MergeMethod = changed(project.MergeMethod, settings.MergeMethod)
+	options.SquashOption = changed(project.SquashOption, settings.SquashOption)
+	options.OnlyAllowMergeIfPipelineSucceeds = changed(project.OnlyAllowMergeIfPipelineSucceeds, settings.OnlyAllowMergeIfPipelineSucceeds)
+	options.AllowMergeOnSkippedPipeline = changed(project.AllowMergeOnSkippedPipeline, settings.AllowMergeOnSkippedPipeline)
+	options.OnlyAllowMergeIfAllDiscussionsAreResolved = changed(project.OnlyAllowMergeIfAllDiscussionsAreResolved, settings.OnlyAllowMergeIfAllDiscussionsAreResolved)
+	options.ResolveOutdatedDiffDiscussions = changed(project.ResolveOutdatedDiffDiscussions, settings.ResolveOutdatedDiffDiscussions)
+	options.RemoveSourceBranchAfterMerge = changed(project.RemoveSourceBranchAfterMerge, settings.RemoveSourceBranchAfterMerge)
+	options.DefaultBranch = changed(project.DefaultBranch, settings.DefaultBranch)
+	options.MergeCommitTemplate = changed(project.MergeCommitTemplate, settings.MergeCommitTemplate)
+	options.SquashCommitTemplate = changed(project.SquashCommitTemplate, settings.SquashCommitTemplate)
+	return options
+}
@@ -0,0 +1,212 @@
+package gitlabapi
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/xanzy/go-gitlab"
+)
+
+// UnprotectBranch removes the protection of the branch or wildcard pattern. A branch
+// that is not protected is left alone.
+func UnprotectBranch(client *gitlab.Client, projectID int, branch string) error {
+	resp, err := client.ProtectedBranches.UnprotectRepositoryBranches(projectID, branch)
+	if err != nil {
+		if resp != nil && resp.StatusCode == http.StatusNotFound {
+			fmt.Printf("Branch %s is not protected\n", branch)
+			return nil
+		}
+		return fmt.Errorf("failed to unprotect branch %s: %v", branch, err)
+	}
+	fmt.Printf("Unprotected branch: %s\n", branch)
+	return nil
+}
+
+// EnsureTagProtection protects the tag or wildcard pattern (e.g. v*) so that only the
+// given access level can create matching tags. An existing protection that differs is
+// replaced; a matching one is left alone. Users and groups allowed to create tags are a
+// Premium feature and are not managed: they are kept when the protection is replaced.
+// If the new protection is rejected, the previous one is restored.
+func EnsureTagProtection(client *gitlab.Client, projectID int, tag string, createAccessLevel gitlab.AccessLevelValue) error {
+	current, resp, err := client.ProtectedTags.GetProtectedTag(projectID, tag)
+	if err != nil && (resp == nil || resp.StatusCode != http.StatusNotFound) {
+		return fmt.Errorf("failed to get protection of tag %s: %v", tag, err)
+	}
+
+	var kept []*gitlab.TagsPermissionOptions
+	if err == nil {
+		roles, allowed := tagPermissions(current.CreateAccessLevels)
+		if len(roles) == 1 && roles[0] == createAccessLevel {
+			fmt.Printf("Tag %s is already protected as requested\n", tag)
+			return nil
+		}
+		kept = allowed
+
+		// Protected tags have no update endpoint, so the protection is recreated
+		_, err = client.ProtectedTags.UnprotectRepositoryTags(projectID, tag)
+		if err != nil {
+			return fmt.Errorf("failed to unprotect tag %s: %v", tag, err)
+		}
+	}
+
+	options := &gitlab.ProtectRepositoryTagsOptions{
+		Name:              gitlab.String(tag),
+		CreateAccessLevel: gitlab.AccessLevel(createAccessLevel),
+	}
+	if len(kept) > 0 {
+		options.AllowedToCreate = &kept
+	}
+	_, _, err = client.ProtectedTags.ProtectRepositoryTags(projectID, options)
+	if err != nil {
+		if current == nil {
+			return fmt.Errorf("failed to protect tag %s: %v", tag, err)
+		}
+		if restoreErr := restoreTagProtection(client, projectID, current); restoreErr != nil {
+			return fmt.Errorf("failed to protect tag %s: %v; the tag is now unprotected: %v", tag, err, restoreErr)
+		}
+		return fmt.Errorf("failed to protect tag %s: %v; previous protection restored", tag, err)
+	}
+
+	if current != nil {
+		fmt.Printf("Updated protection of tag: %s\n", tag)
+	} else {
+		fmt.Printf("Protected tag: %s\n", tag)
+	}
+	return nil
+}
+
+// restoreTagProtection protects the tag again with the create access it had before.
+// Users and groups are only sent when the previous protection had any, so restoring
+// works on GitLab Free.
+func restoreTagProtection(client *gitlab.Client, projectID int, previous *gitlab.ProtectedTag) error {
+	roles, allowed := tagPermissions(previous.CreateAccessLevels)
+	options := &gitlab.ProtectRepositoryTagsOptions{
+		Name: gitlab.String(previous.Name),
+	}
+	if len(roles) == 1 {
+		options.CreateAccessLevel = gitlab.AccessLevel(roles[0])
+	} else {
+		for _, role := range roles {
+			allowed = append(allowed, &gitlab.TagsPermissionOptions{AccessLevel: gitlab.AccessLevel(role)})
+		}
+	}
+	if len(allowed) > 0 {
+		options.AllowedToCreate = &allowed
+	}
+
+	_, _, err := client.ProtectedTags.ProtectRepositoryTags(projectID, options)
+	if err != nil {
+		return fmt.Errorf("failed to restore protection of tag %s: %v", previous.Name, err)
+	}
+	return nil
+}
+
+// tagPermissions splits the create access of a protected tag into its roles and the
+// users and groups allowed to create tags.
+func tagPermissions(levels []*gitlab.TagAccessDescription) ([]gitlab.AccessLevelValue, []*gitlab.TagsPermissionOptions) {
+	var roles []gitlab.AccessLevelValue
+	var allowed []*gitlab.TagsPermissionOptions
+	for _, level := range levels {
+		switch {
+		case level.UserID != 0:
+			allowed = append(allowed, &gitlab.TagsPermissionOptions{UserID: gitlab.Int(level.UserID)})
+		case level.GroupID != 0:
+			allowed = append(allowed, &gitlab.TagsPermissionOptions{GroupID: gitlab.Int(level.GroupID)})
+		default:
+			roles = append(roles, level.AccessLevel)
+		}
+	}
+	return roles, allowed
+}
+
+// UnprotectTag removes the protection of the tag or wildcard pattern. A tag that is
+// not protected is left alone.
+func UnprotectTag(client *gitlab.Client, projectID int, tag string) error {
+	resp, err := client.ProtectedTags.UnprotectRepositoryTags(projectID, tag)
+	if err != nil {
+		if resp != nil && resp.StatusCode == http.StatusNotFound {
+			fmt.Printf("Tag %s is not protected\n", tag)
+			return nil
+		}
+		return fmt.Errorf("failed to unprotect tag %s: %v", tag, err)
+	}
+	fmt.Printf("Unprotected tag: %s\n", tag)
+	return nil
+}
+
+// NewProtectionReport returns an empty report with the protection state columns.
+func NewProtectionReport() *Report {
+	return &Report{Headers: []string{"Project", "Type", "Name", "Push", "Merge", "Unprotect", "Create", "Force Push", "Code Owners"}}
+}
+
+// ProtectionState adds one row per protected branch and protected tag of the project
+// to the report. Nothing is modified.
+func ProtectionState(client *gitlab.Client, project *gitlab.Project, report *Report) error {
+	err := Paginate(client, project.ID, func(options gitlab.ListOptions) (*gitlab.Response, error) {
+		branches, resp, err := client.ProtectedBranches.ListProtectedBranches(project.ID, &gitlab.ListProtectedBranchesOptions{
+			ListOptions: options,
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, branch := range branches {
+			report.AddRow(
+				project.PathWithNamespace,
+				"branch",
+				branch.Name,
+				describeBranchAccess(branch.PushAccessLevels),
+				describeBranchAccess(branch.MergeAccessLevels),
+				describeBranchAccess(branch.UnprotectAccessLevels),
+				"",
+				strconv.FormatBool(branch.AllowForcePush),
+				strconv.FormatBool(branch.CodeOwnerApprovalRequired),
+			)
+		}
+		return resp, nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list protected branches: %v", err)
+	}
+
+	err = Paginate(client, project.ID, func(options gitlab.ListOptions) (*gitlab.Response, error) {
+		listOptions := gitlab.ListProtectedTagsOptions(options)
+		tags, resp, err := client.ProtectedTags.ListProtectedTags(project.ID, &listOptions)
+		if err != nil {
+			return nil, err
+		}
+		for _, tag := range tags {
+			var levels []string
+			for _, level := range tag.CreateAccessLevels {
+				levels = append(levels, describeAccess(level.AccessLevel, level.UserID, level.GroupID))
+			}
+			report.AddRow(project.PathWithNamespace, "tag", tag.Name, "", "", "", strings.Join(levels, ", "), "", "")
+		}
+		return resp, nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list protected tags: %v", err)
+	}
+	return nil
+}
+
+// describeBranchAccess joins the access descriptions of one kind for a report cell.
+func describeBranchAccess(levels []*gitlab.BranchAccessDescription) string {
+	var descriptions []string
+	for _, level := range levels {
+		descriptions = append(descriptions, describeAccess(level.AccessLevel, level.UserID, level.GroupID))
+	}
+	return strings.Join(descriptions, ", ")
+}
+
+// describeAccess describes a role, user or group grant.
+func describeAccess(level gitlab.AccessLevelValue, userID, groupID int) string {
+	switch {
+	case userID != 0:
+		return "user:" + strconv.Itoa(userID)
+	case groupID != 0:
+		return "group:" + strconv.Itoa(groupID)
+	}
+	return accessLevelName(level)
+}
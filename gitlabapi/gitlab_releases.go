@@ -0,0 +1,334 @@
+package gitlabapi
+
+import (
+	"fmt"
+	"net/http"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/xanzy/go-gitlab"
+)
+
+const (
+	maxReleaseNotesSize = 100000 // Maximum size of generated release notes
+	maxChangelogCommits = 200    // Most commits listed in the notes of a first release
+)
+
+// ReleaseAsset is a link added to every release. The URL is a template with the
+// fields of ReleaseAssetData, e.g. https://artifacts.example.com/{{.Path}}/{{.Tag}}.tar.gz.
+type ReleaseAsset struct {
+	Name string
+	URL  string
+}
+
+// ReleaseAssetData is the data available to asset URL templates.
+type ReleaseAssetData struct {
+	Name string // Project path, e.g. "my-service"
+	Path string // Project path with namespace, e.g. "group/my-service"
+	Tag  string
+}
+
+// ReleaseOptions configures how tags and releases are created.
+type ReleaseOptions struct {
+	Ref             RefSpec
+	TagTemplate     string // Tag name template with the fields of BranchNameData
+	VersionFile     string // File the {{.Version}} of the template is read from, optional
+	PreviousPattern string // Pattern of the tags the changelog starts from, default "*"
+	Assets          []ReleaseAsset
+	RequirePipeline bool // Only release when the latest pipeline of the commit succeeded
+	DryRun          bool
+}
+
+// changelogSections are the conventional-commit types in the order they appear in the
+// release notes. Commits of other types are listed under "Other Changes".
+var changelogSections = []struct {
+	Type  string
+	Title string
+}{
+	{"feat", "Features"},
+	{"fix", "Bug Fixes"},
+	{"perf", "Performance Improvements"},
+	{"refactor", "Refactoring"},
+	{"docs", "Documentation"},
+	{"build", "Build"},
+	{"ci", "Continuous Integration"},
+	{"test", "Tests"},
+	{"chore", "Chores"},
+}
+
+// conventionalCommitRegex matches "type(scope)!: subject" commit titles.
+var conventionalCommitRegex = regexp.MustCompile(`^(\w+)(?:\(([^)]*)\))?(!)?:\s*(.+)$`)
+
+// NewReleaseReport returns an empty report with the release columns.
+func NewReleaseReport() *Report {
+	return &Report{Headers: []string{"Project", "Tag", "Commit", "Previous Tag", "Commits", "Result"}}
+}
+
+// CreateRelease tags the resolved ref of the project and creates a release whose notes
+// list the commits since the previous tag, grouped by conventional-commit type.
+// Projects that already have the tag are skipped.
+func CreateRelease(client *gitlab.Client, project *gitlab.Project, options ReleaseOptions, report *Report) error {
+	ref, err := ResolveRef(client, project, options.Ref)
+	if err != nil {
+		return err
+	}
+
+	commit, _, err := client.Commits.GetCommit(project.ID, ref)
+	if err != nil {
+		return fmt.Errorf("failed to get commit of %s: %v", ref, err)
+	}
+
+	tagName, err := RenderTagName(client, project, commit.ID, options.TagTemplate, options.VersionFile)
+	if err != nil {
+		return err
+	}
+
+	_, resp, err := client.Tags.GetTag(project.ID, tagName)
+	if err == nil {
+		report.AddRow(project.PathWithNamespace, tagName, commit.ShortID, "", "", "tag already exists")
+		return nil
+	}
+	if resp == nil || resp.StatusCode != http.StatusNotFound {
+		return fmt.Errorf("failed to check tag %s: %v", tagName, err)
+	}
+
+	if options.RequirePipeline {
+		status, err := latestPipelineStatus(client, project.ID, commit.ID)
+		if err != nil {
+			return err
+		}
+		if status != "success" {
+			report.AddRow(project.PathWithNamespace, tagName, commit.ShortID, "", "", "skipped: pipeline "+status)
+			return nil
+		}
+	}
+
+	pattern := options.PreviousPattern
+	if pattern == "" {
+		pattern = "*"
+	}
+	previous, err := findPreviousTag(client, project.ID, pattern, commit.ID)
+	if err != nil {
+		return err
+	}
+
+	commits, capped, err := commitsSince(client, project.ID, previous, commit.ID)
+	if err != nil {
+		return err
+	}
+	notes := buildReleaseNotes(commits)
+	if capped {
+		notes += fmt.Sprintf("\n\n_Only the latest %d commits are listed._", maxChangelogCommits)
+	}
+	notes = truncateDescription(notes, maxReleaseNotesSize)
+
+	links, err := releaseLinks(project, tagName, options.Assets)
+	if err != nil {
+		return err
+	}
+
+	result := "created"
+	if options.DryRun {
+		result = "dry run"
+		fmt.Printf("Release notes for %s %s:\n%s\n", project.PathWithNamespace, tagName, notes)
+	} else {
+		_, _, err = client.Releases.CreateRelease(project.ID, &gitlab.CreateReleaseOptions{
+			Name:        gitlab.String(tagName),
+			TagName:     gitlab.String(tagName),
+			Ref:         gitlab.String(commit.ID),
+			Description: gitlab.String(notes),
+			Assets:      &gitlab.ReleaseAssetsOptions{Links: links},
+		})
+		if err != nil {
+			result = err.Error()
+		}
+	}
+	report.AddRow(project.PathWithNamespace, tagName, commit.ShortID, previous, strconv.Itoa(len(commits)), result)
+	return nil
+}
+
+// latestPipelineStatus returns the status of the latest pipeline of the commit, or
+// "missing" if it has none.
+func latestPipelineStatus(client *gitlab.Client, projectID int, sha string) (string, error) {
+	pipelines, _, err := client.Pipelines.ListProjectPipelines(projectID, &gitlab.ListProjectPipelinesOptions{
+		ListOptions: gitlab.ListOptions{PerPage: 1},
+		SHA:         gitlab.String(sha),
+		OrderBy:     gitlab.String("id"),
+		Sort:        gitlab.String("desc"),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to list pipelines of %s: %v", sha, err)
+	}
+	if len(pipelines) == 0 {
+		return "missing", nil
+	}
+	return pipelines[0].Status, nil
+}
+
+// findPreviousTag returns the newest tag matching the pattern whose commit is an
+// ancestor of sha, or an empty string if there is none. Tags on other branches, such
+// as maintenance releases, are skipped.
+func findPreviousTag(client *gitlab.Client, projectID int, pattern, sha string) (string, error) {
+	var found string
+	var compareErr error
+	err := Paginate(client, projectID, func(options gitlab.ListOptions) (*gitlab.Response, error) {
+		tags, resp, err := client.Tags.ListTags(projectID, &gitlab.ListTagsOptions{
+			ListOptions: options,
+			OrderBy:     gitlab.String("updated"),
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, tag := range tags {
+			if ok, _ := path.Match(pattern, tag.Name); !ok || tag.Commit == nil {
+				continue
+			}
+
+			// The tag is an ancestor when it has no commits that sha does not have
+			compare, _, err := client.Repositories.Compare(projectID, &gitlab.CompareOptions{
+				From: gitlab.String(sha),
+				To:   gitlab.String(tag.Commit.ID),
+			})
+			if err != nil {
+				compareErr = fmt.Errorf("failed to compare %s with %s: %v", tag.Name, sha, err)
+				resp.NextPage = 0
+				break
+			}
+			if len(compare.Commits) == 0 {
+				found = tag.Name
+				// Stop paginating once the nearest ancestor is found
+				resp.NextPage = 0
+				break
+			}
+		}
+		return resp, nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to list tags: %v", err)
+	}
+	return found, compareErr
+}
+
+// commitsSince returns the commits reachable from sha but not from the previous tag.
+// When there is no previous tag, only the latest maxChangelogCommits commits of sha are
+// returned and capped reports whether there were more.
+func commitsSince(client *gitlab.Client, projectID int, previous, sha string) (commits []*gitlab.Commit, capped bool, err error) {
+	if previous != "" {
+		compare, _, err := client.Repositories.Compare(projectID, &gitlab.CompareOptions{
+			From: gitlab.String(previous),
+			To:   gitlab.String(sha),
+		})
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to compare %s with %s: %v", previous, sha, err)
+		}
+		return compare.Commits, false, nil
+	}
+
+	err = Paginate(client, projectID, func(options gitlab.ListOptions) (*gitlab.Response, error) {
+		page, resp, err := client.Commits.ListCommits(projectID, &gitlab.ListCommitsOptions{
+			ListOptions: options,
+			RefName:     gitlab.String(sha),
+		})
+		if err != nil {
+			return nil, err
+		}
+		commits = append(commits, page...)
+		if len(commits) > maxChangelogCommits {
+			commits = commits[:maxChangelogCommits]
+			capped = true
+			resp.NextPage = 0
+		}
+		return resp, nil
+	})
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to list commits of %s: %v", sha, err)
+	}
+	return commits, capped, nil
+}
+
+// buildReleaseNotes groups the commit titles by conventional-commit type. Breaking
+// changes are listed first and merge commits are left out.
+func buildReleaseNotes(commits []*gitlab.Commit) string {
+	grouped := map[string][]string{}
+	var breaking, other []string
+	for _, commit := range commits {
+		if len(commit.ParentIDs) > 1 {
+			continue
+		}
+
+		match := conventionalCommitRegex.FindStringSubmatch(commit.Title)
+		if match == nil {
+			other = append(other, fmt.Sprintf("- %s (%s)", commit.Title, commit.ShortID))
+			continue
+		}
+
+		entry := match[4]
+		if match[2] != "" {
+			entry = "**" + match[2] + ":** " + entry
+		}
+		entry = fmt.Sprintf("- %s (%s)", entry, commit.ShortID)
+
+		if match[3] != "" {
+			breaking = append(breaking, entry)
+		}
+		commitType := strings.ToLower(match[1])
+		if isChangelogType(commitType) {
+			grouped[commitType] = append(grouped[commitType], entry)
+		} else {
+			other = append(other, entry)
+		}
+	}
+
+	var sb strings.Builder
+	section := func(title string, entries []string) {
+		if len(entries) == 0 {
+			return
+		}
+		fmt.Fprintf(&sb, "### %s\n\n%s\n\n", title, strings.Join(entries, "\n"))
+	}
+	section("Breaking Changes", breaking)
+	for _, s := range changelogSections {
+		section(s.Title, grouped[s.Type])
+	}
+	section("Other Changes", other)
+
+	if sb.Len() == 0 {
+		return "No changes."
+	}
+	return strings.TrimSpace(sb.String())
+}
+
+// isChangelogType reports whether the commit type has its own changelog section.
+func isChangelogType(commitType string) bool {
+	for _, s := range changelogSections {
+		if s.Type == commitType {
+			return true
+		}
+	}
+	return false
+}
+
+// releaseLinks renders the asset URL templates for the project and tag.
+func releaseLinks(project *gitlab.Project, tag string, assets []ReleaseAsset) ([]*gitlab.ReleaseAssetLinkOptions, error) {
+	data := ReleaseAssetData{Name: project.Path, Path: project.PathWithNamespace, Tag: tag}
+
+	var links []*gitlab.ReleaseAssetLinkOptions
+	for _, asset := range assets {
+		tmpl, err := template.New("asset").Option("missingkey=error").Parse(asset.URL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid asset URL template %s: %v", asset.URL, err)
+		}
+		var sb strings.Builder
+		if err := tmpl.Execute(&sb, data); err != nil {
+			return nil, fmt.Errorf("failed to render asset URL %s: %v", asset.URL, err)
+		}
+		links = append(links, &gitlab.ReleaseAssetLinkOptions{
+			Name: gitlab.String(asset.Name),
+			URL:  gitlab.String(sb.String()),
+		})
+	}
+	return links, nil
+}
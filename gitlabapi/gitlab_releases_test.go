@@ -0,0 +1,61 @@
+package gitlabapi
+
+import (
+	"testing"
+
+	"github.com/xanzy/go-gitlab"
+)
+
+func TestBuildReleaseNotes(t *testing.T) {
+	commit := func(title, shortID string, parents ...string) *gitlab.Commit {
+		return &gitlab.Commit{Title: title, ShortID: shortID, ParentIDs: parents}
+	}
+
+	tests := []struct {
+		name    string
+		commits []*gitlab.Commit
+		want    string
+	}{
+		{
+			name:    "no commits",
+			commits: nil,
+			want:    "No changes.",
+		},
+		{
+			name: "grouped by type in section order",
+			commits: []*gitlab.Commit{
+				commit("fix(api): handle 404", "b1"),
+				commit("feat: add releases", "a1"),
+				commit("update readme", "c1"),
+				commit("style: format", "d1"),
+			},
+			want: "### Features\n\n- add releases (a1)\n\n" +
+				"### Bug Fixes\n\n- **api:** handle 404 (b1)\n\n" +
+				"### Other Changes\n\n- update readme (c1)\n- format (d1)",
+		},
+		{
+			name: "breaking changes first and merge commits left out",
+			commits: []*gitlab.Commit{
+				commit("Merge branch 'feature' into 'main'", "m1", "p1", "p2"),
+				commit("feat!: drop v1 API", "a2"),
+			},
+			want: "### Breaking Changes\n\n- drop v1 API (a2)\n\n" +
+				"### Features\n\n- drop v1 API (a2)",
+		},
+		{
+			name: "type is case-insensitive",
+			commits: []*gitlab.Commit{
+				commit("Docs: explain tags", "e1"),
+			},
+			want: "### Documentation\n\n- explain tags (e1)",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := buildReleaseNotes(tt.commits); got != tt.want {
+				t.Errorf("buildReleaseNotes() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
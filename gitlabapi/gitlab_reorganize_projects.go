@@ -0,0 +1,190 @@
+package gitlabapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path"
+	"strconv"
+	"time"
+
+	"github.com/xanzy/go-gitlab"
+)
+
+// Operations for reorganising the projects of a group.
+const (
+	ReorganizeArchive   = "archive"   // Archive projects without activity for N days
+	ReorganizeUnarchive = "unarchive" // Unarchive archived projects
+	ReorganizeTransfer  = "transfer"  // Transfer projects to another namespace
+	ReorganizeRename    = "rename"    // Change project names and paths from a mapping file
+)
+
+// ProjectRename is the new name and path of a project. Empty fields are kept.
+type ProjectRename struct {
+	Name string `json:"name"`
+	Path string `json:"path"` // Last path segment only, e.g. "new-service"
+}
+
+// ReorganizeOptions configures how the projects of a group are reorganised.
+type ReorganizeOptions struct {
+	Operation    string
+	Matcher      *PathMatcher             // Project paths with namespace to include; required for transfer
+	InactiveDays int                      // Used with ReorganizeArchive
+	Namespace    string                   // Target namespace path or ID, used with ReorganizeTransfer
+	Renames      map[string]ProjectRename // Project path with namespace to rename, used with ReorganizeRename
+	DryRun       bool
+}
+
+// LoadProjectRenames reads a JSON mapping of project paths with namespace to their new
+// name and path.
+func LoadProjectRenames(path string) (map[string]ProjectRename, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	renames := map[string]ProjectRename{}
+	if err := json.Unmarshal(content, &renames); err != nil {
+		return nil, fmt.Errorf("failed to parse rename mapping %s: %v", path, err)
+	}
+	return renames, nil
+}
+
+// NewReorganizeReport returns an empty report with the reorganisation columns. The ID,
+// old path and old name of every changed project are kept so that it can be rolled back.
+func NewReorganizeReport() *Report {
+	return &Report{Headers: []string{"Project ID", "Old Path", "Old Name", "New Path", "New Name", "Operation", "Result"}}
+}
+
+// ProjectReorganization is a planned change of one project.
+type ProjectReorganization struct {
+	Project *gitlab.Project
+	NewPath string // Path with namespace after the change
+	NewName string
+	apply   func() error
+}
+
+// PlanReorganization lists the projects of the group and returns the changes the
+// operation makes to the matching ones. All projects are listed before anything
+// changes, because transferred projects leave the group and would shift the pages.
+func PlanReorganization(client *gitlab.Client, groupID int, options ReorganizeOptions) ([]*ProjectReorganization, error) {
+	if options.Operation == ReorganizeTransfer && (options.Matcher == nil || options.Namespace == "") {
+		return nil, fmt.Errorf("transfer needs project patterns and a target namespace")
+	}
+
+	// Resolve the namespace so that an ID gives the right new path
+	var namespace *gitlab.Namespace
+	if options.Operation == ReorganizeTransfer {
+		var err error
+		namespace, _, err = client.Namespaces.GetNamespace(options.Namespace)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get namespace %s: %v", options.Namespace, err)
+		}
+	}
+
+	var projects []*gitlab.Project
+	err := Paginate(client, groupID, func(listOptions gitlab.ListOptions) (*gitlab.Response, error) {
+		page, resp, err := client.Groups.ListGroupProjects(groupID, &gitlab.ListGroupProjectsOptions{
+			ListOptions: listOptions,
+		})
+		if err != nil {
+			return nil, err
+		}
+		projects = append(projects, page...)
+		return resp, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list projects: %v", err)
+	}
+
+	cutoff := time.Now().AddDate(0, 0, -options.InactiveDays)
+	var changes []*ProjectReorganization
+	for _, project := range projects {
+		if options.Matcher != nil && !options.Matcher.Match(project.PathWithNamespace) {
+			continue
+		}
+
+		change := &ProjectReorganization{Project: project, NewPath: project.PathWithNamespace, NewName: project.Name}
+		switch options.Operation {
+		case ReorganizeArchive:
+			if project.Archived || project.LastActivityAt == nil || !project.LastActivityAt.Before(cutoff) {
+				continue
+			}
+			change.apply = func() error {
+				_, _, err := client.Projects.ArchiveProject(project.ID)
+				return err
+			}
+		case ReorganizeUnarchive:
+			if !project.Archived {
+				continue
+			}
+			change.apply = func() error {
+				_, _, err := client.Projects.UnarchiveProject(project.ID)
+				return err
+			}
+		case ReorganizeTransfer:
+			if project.Namespace != nil && project.Namespace.ID == namespace.ID {
+				continue
+			}
+			change.NewPath = path.Join(namespace.FullPath, project.Path)
+			change.apply = func() error {
+				_, _, err := client.Projects.TransferProject(project.ID, &gitlab.TransferProjectOptions{
+					Namespace: namespace.ID,
+				})
+				return err
+			}
+		case ReorganizeRename:
+			rename, ok := options.Renames[project.PathWithNamespace]
+			if !ok {
+				continue
+			}
+			edit := &gitlab.EditProjectOptions{}
+			if rename.Name != "" && rename.Name != project.Name {
+				change.NewName = rename.Name
+				edit.Name = gitlab.String(rename.Name)
+			}
+			if rename.Path != "" && rename.Path != project.Path {
+				change.NewPath = path.Join(path.Dir(project.PathWithNamespace), rename.Path)
+				edit.Path = gitlab.String(rename.Path)
+			}
+			if edit.Name == nil && edit.Path == nil {
+				continue
+			}
+			change.apply = func() error {
+				_, _, err := client.Projects.EditProject(project.ID, edit)
+				return err
+			}
+		default:
+			return nil, fmt.Errorf("unknown reorganize operation: %s", options.Operation)
+		}
+		changes = append(changes, change)
+	}
+	return changes, nil
+}
+
+// ApplyReorganization makes the planned change and records it in the report. The change
+// is written to the report before it is made, so with an open journal the old path is
+// saved even if the run is aborted. A failed change is recorded and returned as an
+// error. In dry-run mode the change is only previewed.
+func ApplyReorganization(change *ProjectReorganization, operation string, dryRun bool, report *Report) error {
+	project := change.Project
+	row := func(result string) []string {
+		return []string{strconv.Itoa(project.ID), project.PathWithNamespace, project.Name, change.NewPath, change.NewName, operation, result}
+	}
+
+	if dryRun {
+		report.AddRow(row("dry run")...)
+		return nil
+	}
+
+	// Record the change before making it, and do not make what was not recorded
+	if err := report.WriteRow(row("applying")...); err != nil {
+		return err
+	}
+	if err := change.apply(); err != nil {
+		report.AddRow(row(err.Error())...)
+		return fmt.Errorf("failed to %s project: %v", operation, err)
+	}
+	report.AddRow(row("ok")...)
+	return nil
+}
@@ -0,0 +1,118 @@
+package gitlabapi
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+
+	"github.com/xanzy/go-gitlab"
+)
+
+// Replacement replaces every match of Pattern with Replacement, which may refer to
+// capture groups as $1 or ${name}.
+type Replacement struct {
+	Pattern     *regexp.Regexp
+	Replacement string
+}
+
+// ReplaceOptions describes a search-and-replace across repository files.
+type ReplaceOptions struct {
+	BaseBranch   string // Defaults to the project's default branch
+	Branch       string
+	Matcher      *PathMatcher
+	Replacements []Replacement
+	DryRun       bool // Print a unified diff per file instead of committing
+}
+
+// ReplaceInFiles applies the replacements to every file matching the options. In dry-run
+// mode the changes are printed as unified diffs; otherwise all changed files are
+// committed in a single commit on a new branch with a merge request.
+func ReplaceInFiles(client *gitlab.Client, project *gitlab.Project, options ReplaceOptions) error {
+	baseBranch := options.BaseBranch
+	if baseBranch == "" {
+		baseBranch = project.DefaultBranch
+	}
+
+	files, err := listRepositoryFiles(client, project.ID, baseBranch)
+	if err != nil {
+		return err
+	}
+
+	var actions []*gitlab.CommitActionOptions
+	var changed []string
+	for _, node := range files {
+		if !options.Matcher.Match(node.Path) {
+			continue
+		}
+
+		file, _, err := client.RepositoryFiles.GetFile(project.ID, node.Path, &gitlab.GetFileOptions{
+			Ref: gitlab.String(baseBranch),
+		})
+		if err != nil {
+			log.Printf("Failed to read file %s for project %s: %v\n", node.Path, project.Name, err)
+			continue
+		}
+		content, err := base64.StdEncoding.DecodeString(file.Content)
+		if err != nil {
+			log.Printf("Failed to decode file %s for project %s: %v\n", node.Path, project.Name, err)
+			continue
+		}
+
+		// Skip binary files
+		if bytes.IndexByte(content, 0) >= 0 {
+			continue
+		}
+
+		updated := string(content)
+		for _, r := range options.Replacements {
+			updated = r.Pattern.ReplaceAllString(updated, r.Replacement)
+		}
+		if updated == string(content) {
+			continue
+		}
+
+		changed = append(changed, node.Path)
+		if options.DryRun {
+			fmt.Print(UnifiedDiff(node.Path, string(content), updated))
+			continue
+		}
+
+		actions = append(actions, &gitlab.CommitActionOptions{
+			Action:       gitlab.FileAction(gitlab.FileUpdate),
+			FilePath:     gitlab.String(node.Path),
+			Content:      gitlab.String(updated),
+			LastCommitID: gitlab.String(file.LastCommitID),
+		})
+	}
+
+	if len(changed) == 0 {
+		log.Printf("No matches found for project %s, skipping project\n", project.Name)
+		return nil
+	}
+	if options.DryRun {
+		fmt.Printf("Dry run: %d file(s) would change in project %s\n", len(changed), project.Name)
+		return nil
+	}
+
+	var description strings.Builder
+	description.WriteString("Applies the following replacements:\n\n")
+	for _, r := range options.Replacements {
+		fmt.Fprintf(&description, "- `%s` → `%s`\n", r.Pattern, r.Replacement)
+	}
+	description.WriteString("\nChanged files:\n\n")
+	for _, path := range changed {
+		fmt.Fprintf(&description, "- `%s`\n", path)
+	}
+
+	return CommitAndCreateMergeRequest(client, project.ID, ChangeRequest{
+		BaseBranch:    baseBranch,
+		Branch:        options.Branch,
+		CommitMessage: fmt.Sprintf("Replace content in %d file(s)", len(changed)),
+		Title:         fmt.Sprintf("Replace content in %d file(s)", len(changed)),
+		Description:   description.String(),
+		Actions:       actions,
+	})
+}
@@ -0,0 +1,177 @@
+package gitlabapi
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+)
+
+// Report output formats.
+const (
+	FormatTable = "table"
+	FormatJSON  = "json"
+	FormatCSV   = "csv"
+)
+
+// ValidateReportFormat returns an error if the format is not one of the report formats.
+// An empty format selects FormatTable.
+func ValidateReportFormat(format string) error {
+	switch format {
+	case "", FormatTable, FormatJSON, FormatCSV:
+		return nil
+	}
+	return fmt.Errorf("unknown report format: %s", format)
+}
+
+// Report is a table of results collected across projects.
+type Report struct {
+	Headers []string
+	Rows    [][]string
+
+	journal    *os.File    // CSV file rows are written to as they are added, see OpenJournal
+	journalCSV *csv.Writer // Writer of the journal
+	journalErr error       // First error writing the journal, returned by Close
+}
+
+// AddRow appends a row to the report. If a journal is open, the row is also written to
+// it; use WriteRow when the caller must know that the row was saved.
+func (r *Report) AddRow(values ...string) {
+	if err := r.WriteRow(values...); err != nil && r.journalErr == nil {
+		r.journalErr = err
+	}
+}
+
+// WriteRow appends a row to the report and, if a journal is open, writes and flushes it
+// to the journal before returning.
+func (r *Report) WriteRow(values ...string) error {
+	r.Rows = append(r.Rows, values)
+	if r.journalCSV == nil {
+		return nil
+	}
+	r.journalCSV.Write(values)
+	r.journalCSV.Flush()
+	if err := r.journalCSV.Error(); err != nil {
+		return fmt.Errorf("failed to write %s: %v", r.journal.Name(), err)
+	}
+	return nil
+}
+
+// ReplaceLastRow replaces the last row of the report, e.g. to record the result of a
+// change whose row was written before it was made. If a journal is open, the new row is
+// appended to it; Close leaves only the replacement in the file.
+func (r *Report) ReplaceLastRow(values ...string) {
+	if len(r.Rows) > 0 {
+		r.Rows = r.Rows[:len(r.Rows)-1]
+	}
+	r.AddRow(values...)
+}
+
+// OpenJournal creates the CSV file at path and writes the headers and every row added
+// from now on to it immediately, so that the record survives a crash or an abort.
+func (r *Report) OpenJournal(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	r.journal = f
+	r.journalCSV = csv.NewWriter(f)
+	r.journalCSV.Write(r.Headers)
+	r.journalCSV.Flush()
+	return r.journalCSV.Error()
+}
+
+// Close closes the journal, if one is open, and returns the first error writing it. The
+// journal is then rewritten as CSV with the final rows, so rows replaced by
+// ReplaceLastRow only remain in it if the run was aborted.
+func (r *Report) Close() error {
+	if r.journal == nil {
+		return r.journalErr
+	}
+	path := r.journal.Name()
+	err := r.journal.Close()
+	r.journal = nil
+	r.journalCSV = nil
+	if r.journalErr != nil {
+		return r.journalErr
+	}
+	if err != nil {
+		return err
+	}
+
+	// Write a copy first so the journal is kept if the final file cannot be written
+	if err := WriteReportFile(path+".tmp", FormatCSV, r); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+// WriteReport writes the report in the given format. JSON output is an array of
+// objects keyed by the lower-cased headers.
+func WriteReport(w io.Writer, format string, report *Report) error {
+	switch format {
+	case "", FormatTable:
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, strings.Join(report.Headers, "\t"))
+		for _, row := range report.Rows {
+			fmt.Fprintln(tw, strings.Join(row, "\t"))
+		}
+		return tw.Flush()
+	case FormatCSV:
+		cw := csv.NewWriter(w)
+		if err := cw.Write(report.Headers); err != nil {
+			return err
+		}
+		if err := cw.WriteAll(report.Rows); err != nil {
+			return err
+		}
+		return cw.Error()
+	case FormatJSON:
+		records := make([]map[string]string, 0, len(report.Rows))
+		for _, row := range report.Rows {
+			record := map[string]string{}
+			for i, header := range report.Headers {
+				if i < len(row) {
+					record[jsonKey(header)] = row[i]
+				}
+			}
+			records = append(records, record)
+		}
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(records)
+	}
+	return fmt.Errorf("unknown report format: %s", format)
+}
+
+// WriteReportFile writes the report to the file at path, or to stdout if path is empty.
+func WriteReportFile(path, format string, report *Report) error {
+	return writeFile(path, func(w io.Writer) error {
+		return WriteReport(w, format, report)
+	})
+}
+
+// writeFile calls write with the file at path, or with stdout if path is empty.
+func writeFile(path string, write func(w io.Writer) error) error {
+	if path == "" {
+		return write(os.Stdout)
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := write(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// jsonKey turns a header such as "Last Activity" into "last_activity".
+func jsonKey(header string) string {
+	return strings.ReplaceAll(strings.ToLower(strings.TrimSpace(header)), " ", "_")
+}
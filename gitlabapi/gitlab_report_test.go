@@ -0,0 +1,40 @@
+package gitlabapi
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReportJournalReplaceLastRow(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "journal.csv")
+	report := &Report{Headers: []string{"Branch", "Result"}}
+	if err := report.OpenJournal(path); err != nil {
+		t.Fatalf("OpenJournal() error = %v", err)
+	}
+
+	if err := report.WriteRow("a", "deleting"); err != nil {
+		t.Fatalf("WriteRow() error = %v", err)
+	}
+	report.ReplaceLastRow("a", "deleted")
+
+	// Until the journal is closed both rows are kept in the file
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "Branch,Result\na,deleting\na,deleted\n"; string(content) != want {
+		t.Errorf("journal before Close =\n%s\nwant\n%s", content, want)
+	}
+
+	if err := report.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	content, err = os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "Branch,Result\na,deleted\n"; string(content) != want {
+		t.Errorf("journal after Close =\n%s\nwant\n%s", content, want)
+	}
+}
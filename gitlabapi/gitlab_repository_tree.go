@@ -0,0 +1,146 @@
+package gitlabapi
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/xanzy/go-gitlab"
+)
+
+// PathMatcher matches repository file paths against include and exclude patterns.
+// Patterns prefixed with "re:" are regular expressions; all others are globs where
+// "**" crosses directories. Globs without a slash match the file name at any depth.
+type PathMatcher struct {
+	include []*regexp.Regexp
+	exclude []*regexp.Regexp
+	prefix  string
+}
+
+// NewPathMatcher compiles the include and exclude patterns. Only paths below prefix
+// match when prefix is not empty.
+func NewPathMatcher(include, exclude []string, prefix string) (*PathMatcher, error) {
+	if len(include) == 0 {
+		return nil, fmt.Errorf("at least one include pattern is required")
+	}
+
+	m := &PathMatcher{prefix: strings.TrimPrefix(prefix, "/")}
+	for _, pattern := range include {
+		re, err := compilePathPattern(pattern)
+		if err != nil {
+			return nil, err
+		}
+		m.include = append(m.include, re)
+	}
+	for _, pattern := range exclude {
+		re, err := compilePathPattern(pattern)
+		if err != nil {
+			return nil, err
+		}
+		m.exclude = append(m.exclude, re)
+	}
+	return m, nil
+}
+
+// Match reports whether the path is included and not excluded.
+func (m *PathMatcher) Match(filePath string) bool {
+	if m.prefix != "" && filePath != m.prefix && !strings.HasPrefix(filePath, strings.TrimSuffix(m.prefix, "/")+"/") {
+		return false
+	}
+	for _, re := range m.exclude {
+		if re.MatchString(filePath) {
+			return false
+		}
+	}
+	for _, re := range m.include {
+		if re.MatchString(filePath) {
+			return true
+		}
+	}
+	return false
+}
+
+// compilePathPattern turns a "re:" regular expression or a glob into a regexp.
+func compilePathPattern(pattern string) (*regexp.Regexp, error) {
+	if strings.HasPrefix(pattern, "re:") {
+		re, err := regexp.Compile(strings.TrimPrefix(pattern, "re:"))
+		if err != nil {
+			return nil, fmt.Errorf("invalid regex %s: %v", pattern, err)
+		}
+		return re, nil
+	}
+
+	if _, err := path.Match(strings.ReplaceAll(pattern, "**", "*"), ""); err != nil {
+		return nil, fmt.Errorf("invalid glob %s: %v", pattern, err)
+	}
+
+	var sb strings.Builder
+	sb.WriteString("^")
+	if !strings.Contains(pattern, "/") {
+		sb.WriteString("(?:.*/)?")
+	}
+	for i := 0; i < len(pattern); i++ {
+		c := pattern[i]
+		switch {
+		case strings.HasPrefix(pattern[i:], "**/"):
+			sb.WriteString("(?:.*/)?")
+			i += 2
+		case strings.HasPrefix(pattern[i:], "**"):
+			sb.WriteString(".*")
+			i++
+		case c == '*':
+			sb.WriteString("[^/]*")
+		case c == '?':
+			sb.WriteString("[^/]")
+		case c == '[':
+			end := strings.IndexByte(pattern[i:], ']')
+			class := pattern[i+1 : i+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			sb.WriteString("[" + class + "]")
+			i += end
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	sb.WriteString("$")
+	return regexp.Compile(sb.String())
+}
+
+// listRepositoryFiles lists all files (blobs) of the repository at ref.
+func listRepositoryFiles(client *gitlab.Client, projectID int, ref string) ([]*gitlab.TreeNode, error) {
+	var files []*gitlab.TreeNode
+	err := Paginate(client, projectID, func(options gitlab.ListOptions) (*gitlab.Response, error) {
+		tree, resp, err := client.Repositories.ListTree(projectID, &gitlab.ListTreeOptions{
+			ListOptions: options,
+			Ref:         gitlab.String(ref),
+			Recursive:   gitlab.Bool(true),
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, node := range tree {
+			if node.Type == "blob" {
+				files = append(files, node)
+			}
+		}
+		return resp, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list files for project %d: %v", projectID, err)
+	}
+	return files, nil
+}
+
+// ParsePatterns splits a comma-separated list of patterns, dropping empty entries.
+func ParsePatterns(list string) []string {
+	var patterns []string
+	for _, pattern := range strings.Split(list, ",") {
+		if pattern = strings.TrimSpace(pattern); pattern != "" {
+			patterns = append(patterns, pattern)
+		}
+	}
+	return patterns
+}
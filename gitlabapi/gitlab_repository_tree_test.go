@@ -0,0 +1,65 @@
+package gitlabapi
+
+import "testing"
+
+func TestCompilePathPattern(t *testing.T) {
+	tests := []struct {
+		pattern string
+		path    string
+		want    bool
+	}{
+		{"*.car", "model.car", true},
+		{"*.car", "dir/sub/model.car", true},
+		{"*.car", "model.cars", false},
+		{"docs/*.md", "docs/readme.md", true},
+		{"docs/*.md", "docs/sub/readme.md", false},
+		{"docs/**/*.md", "docs/readme.md", true},
+		{"docs/**/*.md", "docs/a/b/readme.md", true},
+		{"build/**", "build/out/app", true},
+		{"file?.txt", "file1.txt", true},
+		{"file?.txt", "file10.txt", false},
+		{"[!a]*.go", "main.go", true},
+		{"[!a]*.go", "app.go", false},
+		{"re:^src/.*_test\\.go$", "src/pkg/a_test.go", true},
+		{"re:^src/.*_test\\.go$", "lib/a_test.go", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pattern+" "+tt.path, func(t *testing.T) {
+			re, err := compilePathPattern(tt.pattern)
+			if err != nil {
+				t.Fatalf("compilePathPattern(%q) error = %v", tt.pattern, err)
+			}
+			if got := re.MatchString(tt.path); got != tt.want {
+				t.Errorf("pattern %q matching %q = %v, want %v", tt.pattern, tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompilePathPatternInvalid(t *testing.T) {
+	for _, pattern := range []string{"re:(", "[a"} {
+		if _, err := compilePathPattern(pattern); err == nil {
+			t.Errorf("compilePathPattern(%q) expected an error", pattern)
+		}
+	}
+}
+
+func TestPathMatcherPrefix(t *testing.T) {
+	m, err := NewPathMatcher([]string{"**"}, nil, "docs")
+	if err != nil {
+		t.Fatalf("NewPathMatcher error = %v", err)
+	}
+
+	tests := map[string]bool{
+		"docs":           true,
+		"docs/readme.md": true,
+		"docs2/notes.md": false,
+		"src/docs/a.md":  false,
+	}
+	for path, want := range tests {
+		if got := m.Match(path); got != want {
+			t.Errorf("Match(%q) = %v, want %v", path, got, want)
+		}
+	}
+}
@@ -2,30 +2,403 @@ package gitlabapi
 
 import (
 	"fmt"
+	"log"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/xanzy/go-gitlab"
 )
 
 const (
-	rateLimit = 5 * time.Second // Rate limit set to 5 seconds
+	rateLimit          = 5 * time.Second  // Rate limit set to 5 seconds
+	maxPollInterval    = 60 * time.Second // Longest wait between two pipeline status polls
+	maxPollFailures    = 5                // Consecutive failed polls after which a pipeline is given up
+	triggerDescription = "gitlab-go-util" // Description of the trigger tokens created by this tool
 )
 
-// TriggerPipeline triggers a pipeline for a given project and branch.
-// It returns an error if the pipeline creation fails.
-func TriggerPipeline(client *gitlab.Client, projectID int, branch string) error {
-	// Create a new pipeline
-	pipeline, _, err := client.Pipelines.CreatePipeline(projectID, &gitlab.CreatePipelineOptions{
+// Ways a pipeline can be started.
+const (
+	PipelineAPI          = "api"           // Create a branch pipeline through the pipelines API
+	PipelineTrigger      = "trigger"       // Run a pipeline through a trigger token
+	PipelineMergeRequest = "merge-request" // Run merge request pipelines for MRs matching a branch prefix
+)
+
+// PipelineOptions configures how pipelines are triggered.
+type PipelineOptions struct {
+	Mode      string                            // PipelineAPI, PipelineTrigger or PipelineMergeRequest
+	Variables []*gitlab.PipelineVariableOptions // Not supported by merge request pipelines
+	UserID    int                               // Current user, owner of the trigger tokens; see CurrentUserID
+}
+
+// CurrentUserID returns the ID of the user the client authenticates as. Look it up once
+// per run and pass it in PipelineOptions.
+func CurrentUserID(client *gitlab.Client) (int, error) {
+	user, _, err := client.Users.CurrentUser()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get current user: %v", err)
+	}
+	return user.ID, nil
+}
+
+// PipelineResult is the outcome of a triggered pipeline.
+type PipelineResult struct {
+	Project    string
+	PipelineID int
+	Status     string
+	Duration   time.Duration
+	FailedJobs []string
+	WebURL     string
+
+	projectID    int
+	pollFailures int // Consecutive failed polls, see WaitForPipelines
+}
+
+// Failed reports whether the pipeline finished without succeeding.
+func (r *PipelineResult) Failed() bool {
+	switch r.Status {
+	case "failed", "canceled", "timeout", "unknown":
+		return true
+	}
+	return false
+}
+
+// Blocked reports whether the pipeline stopped at a manual job or approval. It has
+// not failed, but it has not succeeded either.
+func (r *PipelineResult) Blocked() bool {
+	return pipelineBlocked(r.Status)
+}
+
+// NewPipelineReport returns an empty report with the pipeline result columns.
+func NewPipelineReport() *Report {
+	return &Report{Headers: []string{"Project", "Pipeline", "Status", "Duration", "Failed Jobs", "URL"}}
+}
+
+// AddPipelineResult adds the result as a row of a pipeline report.
+func AddPipelineResult(report *Report, result *PipelineResult) {
+	report.AddRow(
+		result.Project,
+		strconv.Itoa(result.PipelineID),
+		result.Status,
+		result.Duration.String(),
+		strings.Join(result.FailedJobs, ", "),
+		result.WebURL,
+	)
+}
+
+// ParsePipelineVariable parses KEY=value into a pipeline variable. A "file:" prefix
+// creates a file type variable.
+func ParsePipelineVariable(definition string) (*gitlab.PipelineVariableOptions, error) {
+	variableType := gitlab.EnvVariableType
+	if strings.HasPrefix(definition, "file:") {
+		variableType = gitlab.FileVariableType
+		definition = strings.TrimPrefix(definition, "file:")
+	}
+
+	key, value, ok := strings.Cut(definition, "=")
+	if !ok || key == "" {
+		return nil, fmt.Errorf("variable must be KEY=value: %s", definition)
+	}
+	return &gitlab.PipelineVariableOptions{
+		Key:          gitlab.String(key),
+		Value:        gitlab.String(value),
+		VariableType: gitlab.String(string(variableType)),
+	}, nil
+}
+
+// TriggerPipeline triggers a pipeline for a given project and branch and returns the
+// current state of the started pipelines. It returns an error if a pipeline creation
+// fails. In PipelineMergeRequest mode branch is a prefix and a pipeline is started for
+// every open merge request whose source branch matches it. Use WaitForPipelines to
+// wait for the results.
+func TriggerPipeline(client *gitlab.Client, project *gitlab.Project, branch string, options PipelineOptions) ([]*PipelineResult, error) {
+	var pipelineIDs []int
+	var err error
+	switch options.Mode {
+	case "", PipelineAPI:
+		pipelineIDs, err = createPipeline(client, project.ID, branch, options)
+	case PipelineTrigger:
+		pipelineIDs, err = runPipelineTrigger(client, project.ID, branch, options)
+	case PipelineMergeRequest:
+		if len(options.Variables) > 0 {
+			return nil, fmt.Errorf("merge request pipelines do not support variables")
+		}
+		pipelineIDs, err = createMergeRequestPipelines(client, project.ID, branch)
+	default:
+		err = fmt.Errorf("unknown pipeline mode: %s", options.Mode)
+	}
+	if err != nil && len(pipelineIDs) == 0 {
+		return nil, err
+	}
+
+	// Pipelines that were started are returned even if others failed to start
+	var results []*PipelineResult
+	for _, pipelineID := range pipelineIDs {
+		result, err := getPipelineResult(client, project, pipelineID)
+		if err != nil {
+			return results, err
+		}
+		results = append(results, result)
+	}
+	return results, err
+}
+
+// WaitForPipelines polls all unfinished pipelines together with exponential backoff
+// until they finish, stop at a manual job or the timeout expires, then collects the
+// names of their failed jobs. The results are updated in place; pipelines still running
+// at the timeout get the status "timeout". A failed poll is logged and retried; a
+// pipeline that cannot be polled maxPollFailures times in a row gets the status
+// "unknown".
+func WaitForPipelines(client *gitlab.Client, results []*PipelineResult, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	interval := rateLimit
+
+	pending := results
+	for len(pending) > 0 {
+		var running []*PipelineResult
+		for _, result := range pending {
+			done, err := pollPipeline(client, result)
+			switch {
+			case err != nil:
+				result.pollFailures++
+				log.Printf("Failed to poll pipeline %d of %s (%d/%d): %v\n", result.PipelineID, result.Project, result.pollFailures, maxPollFailures, err)
+				if result.pollFailures >= maxPollFailures {
+					result.Status = "unknown"
+					continue
+				}
+				running = append(running, result)
+			case !done:
+				result.pollFailures = 0
+				running = append(running, result)
+			}
+		}
+		pending = running
+
+		if len(pending) > 0 && time.Now().Add(interval).After(deadline) {
+			for _, result := range pending {
+				result.Status = "timeout"
+			}
+			return nil
+		}
+		if len(pending) > 0 {
+			time.Sleep(interval)
+			interval = min(interval*2, maxPollInterval)
+		}
+	}
+	return nil
+}
+
+// pollPipeline updates the result with the current state of the pipeline and reports
+// whether it needs no further polling.
+func pollPipeline(client *gitlab.Client, result *PipelineResult) (bool, error) {
+	pipeline, _, err := client.Pipelines.GetPipeline(result.projectID, result.PipelineID)
+	if err != nil {
+		return false, fmt.Errorf("failed to get pipeline: %v", err)
+	}
+	result.Status = pipeline.Status
+	result.Duration = time.Duration(pipeline.Duration) * time.Second
+
+	switch {
+	case pipelineFinished(pipeline.Status):
+		failedJobs, err := listFailedJobs(client, result.projectID, result.PipelineID)
+		if err != nil {
+			return false, err
+		}
+		result.FailedJobs = failedJobs
+		return true, nil
+	case pipelineBlocked(pipeline.Status):
+		return true, nil
+	}
+	return false, nil
+}
+
+// createPipeline creates a pipeline for the branch through the pipelines API.
+func createPipeline(client *gitlab.Client, projectID int, branch string, options PipelineOptions) ([]int, error) {
+	createOptions := &gitlab.CreatePipelineOptions{
 		Ref: gitlab.String(branch),
-	})
+	}
+	if len(options.Variables) > 0 {
+		createOptions.Variables = &options.Variables
+	}
+	pipeline, _, err := client.Pipelines.CreatePipeline(projectID, createOptions)
 	if err != nil {
-		return fmt.Errorf("failed to create pipeline for project: %v", err)
+		return nil, fmt.Errorf("failed to create pipeline for project: %v", err)
 	}
 
 	fmt.Printf("Pipeline created with ID: %d\n", pipeline.ID)
+	return []int{pipeline.ID}, nil
+}
 
-	// Sleep to avoid hitting the rate limit
-	time.Sleep(rateLimit)
+// runPipelineTrigger runs a pipeline for the branch through the trigger endpoint,
+// reusing this tool's trigger token or creating one if none exists. Trigger variables
+// are always passed as environment variables.
+func runPipelineTrigger(client *gitlab.Client, projectID int, branch string, options PipelineOptions) ([]int, error) {
+	token, err := ensureTriggerToken(client, projectID, options.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	variables := map[string]string{}
+	for _, variable := range options.Variables {
+		variables[*variable.Key] = *variable.Value
+	}
+
+	pipeline, _, err := client.PipelineTriggers.RunPipelineTrigger(projectID, &gitlab.RunPipelineTriggerOptions{
+		Ref:       gitlab.String(branch),
+		Token:     gitlab.String(token),
+		Variables: variables,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to run pipeline trigger: %v", err)
+	}
+
+	fmt.Printf("Pipeline triggered with ID: %d\n", pipeline.ID)
+	return []int{pipeline.ID}, nil
+}
+
+// ensureTriggerToken returns the token of the trigger owned by the user with
+// the tool's description, creating the trigger if it does not exist. Tokens of other
+// users are masked by GitLab and cannot be reused.
+func ensureTriggerToken(client *gitlab.Client, projectID, userID int) (string, error) {
+	token := ""
+	err := Paginate(client, projectID, func(options gitlab.ListOptions) (*gitlab.Response, error) {
+		triggers, resp, err := client.PipelineTriggers.ListPipelineTriggers(projectID, (*gitlab.ListPipelineTriggersOptions)(&options))
+		if err != nil {
+			return nil, err
+		}
+		for _, trigger := range triggers {
+			if trigger.Description == triggerDescription && trigger.Owner != nil && trigger.Owner.ID == userID {
+				token = trigger.Token
+				resp.NextPage = 0
+				break
+			}
+		}
+		return resp, nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to list pipeline triggers: %v", err)
+	}
+	if token != "" {
+		return token, nil
+	}
+
+	trigger, _, err := client.PipelineTriggers.AddPipelineTrigger(projectID, &gitlab.AddPipelineTriggerOptions{
+		Description: gitlab.String(triggerDescription),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to create pipeline trigger: %v", err)
+	}
+	fmt.Printf("Created pipeline trigger %d\n", trigger.ID)
+	return trigger.Token, nil
+}
+
+// createMergeRequestPipelines starts a merge request pipeline for every open merge
+// request whose source branch starts with branchPrefix. Pipelines that cannot be
+// created do not stop the others; they are returned together as an error next to the
+// started pipelines.
+func createMergeRequestPipelines(client *gitlab.Client, projectID int, branchPrefix string) ([]int, error) {
+	var mergeRequests []*gitlab.MergeRequest
+	err := Paginate(client, projectID, func(options gitlab.ListOptions) (*gitlab.Response, error) {
+		page, resp, err := client.MergeRequests.ListProjectMergeRequests(projectID, &gitlab.ListProjectMergeRequestsOptions{
+			ListOptions: options,
+			State:       gitlab.String("opened"),
+		})
+		if err != nil {
+			return nil, err
+		}
+		mergeRequests = append(mergeRequests, page...)
+		return resp, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list merge requests: %v", err)
+	}
 
-	return nil // Return nil if the pipeline was created successfully
+	var pipelineIDs []int
+	var failures []string
+	for _, mr := range mergeRequests {
+		if !strings.HasPrefix(mr.SourceBranch, branchPrefix) {
+			continue
+		}
+
+		pipeline, _, err := client.MergeRequests.CreateMergeRequestPipeline(projectID, mr.IID)
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("merge request %d: %v", mr.IID, err))
+			continue
+		}
+		fmt.Printf("Pipeline %d created for merge request %d\n", pipeline.ID, mr.IID)
+		pipelineIDs = append(pipelineIDs, pipeline.ID)
+	}
+
+	if len(failures) > 0 {
+		return pipelineIDs, fmt.Errorf("failed to create merge request pipelines: %s", strings.Join(failures, "; "))
+	}
+	if len(pipelineIDs) == 0 {
+		log.Printf("No merge request pipelines created for project %d\n", projectID)
+	}
+	return pipelineIDs, nil
+}
+
+// getPipelineResult returns the current state of the pipeline without waiting.
+func getPipelineResult(client *gitlab.Client, project *gitlab.Project, pipelineID int) (*PipelineResult, error) {
+	pipeline, _, err := client.Pipelines.GetPipeline(project.ID, pipelineID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pipeline %d: %v", pipelineID, err)
+	}
+	return pipelineResult(project, pipeline, nil), nil
+}
+
+// pipelineFinished reports whether the status is final.
+func pipelineFinished(status string) bool {
+	switch status {
+	case "success", "failed", "canceled", "skipped":
+		return true
+	}
+	return false
+}
+
+// pipelineBlocked reports whether the pipeline waits for a manual job or an approval
+// and will not finish on its own.
+func pipelineBlocked(status string) bool {
+	switch status {
+	case "manual", "blocked":
+		return true
+	}
+	return false
+}
+
+// listFailedJobs returns the names of the failed jobs of the pipeline.
+func listFailedJobs(client *gitlab.Client, projectID, pipelineID int) ([]string, error) {
+	var names []string
+	err := Paginate(client, projectID, func(options gitlab.ListOptions) (*gitlab.Response, error) {
+		jobs, resp, err := client.Jobs.ListPipelineJobs(projectID, pipelineID, &gitlab.ListJobsOptions{
+			ListOptions: options,
+			Scope:       &[]gitlab.BuildStateValue{gitlab.Failed},
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, job := range jobs {
+			if !job.AllowFailure {
+				names = append(names, job.Name)
+			}
+		}
+		return resp, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list jobs of pipeline %d: %v", pipelineID, err)
+	}
+	return names, nil
+}
+
+// pipelineResult converts the pipeline into a result.
+func pipelineResult(project *gitlab.Project, pipeline *gitlab.Pipeline, failedJobs []string) *PipelineResult {
+	return &PipelineResult{
+		Project:    project.PathWithNamespace,
+		PipelineID: pipeline.ID,
+		Status:     pipeline.Status,
+		Duration:   time.Duration(pipeline.Duration) * time.Second,
+		FailedJobs: failedJobs,
+		WebURL:     pipeline.WebURL,
+		projectID:  project.ID,
+	}
 }
@@ -0,0 +1,116 @@
+package gitlabapi
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/xanzy/go-gitlab"
+)
+
+// StaleBranchOptions selects the branches to delete. A branch is stale when it is fully
+// merged into the default branch, or when InactiveDays is set and its last commit is
+// older than that.
+type StaleBranchOptions struct {
+	Matcher      *PathMatcher // Branch name patterns, e.g. feature/*
+	Merged       bool         // Delete branches merged into the default branch
+	InactiveDays int          // Delete branches without commits for N days; 0 to disable
+	DryRun       bool
+}
+
+// NewStaleBranchReport returns an empty report with the deleted branch columns. The head
+// SHA allows recreating a branch that was deleted by mistake.
+func NewStaleBranchReport() *Report {
+	return &Report{Headers: []string{"Project", "Branch", "SHA", "Reason", "Result"}}
+}
+
+// DeleteStaleBranches deletes the stale branches of the project that match the options
+// and adds them to the report. Each branch is written to the report before it is
+// deleted, so with an open journal the SHA is saved even if the run is aborted; the row
+// is then replaced by the result of the deletion. The default branch, protected branches
+// and branches that are the source of an open merge request are never deleted.
+func DeleteStaleBranches(client *gitlab.Client, project *gitlab.Project, options StaleBranchOptions, report *Report) error {
+	openSources, err := openMergeRequestSources(client, project.ID)
+	if err != nil {
+		return err
+	}
+
+	var branches []*gitlab.Branch
+	err = Paginate(client, project.ID, func(listOptions gitlab.ListOptions) (*gitlab.Response, error) {
+		page, resp, err := client.Branches.ListBranches(project.ID, &gitlab.ListBranchesOptions{
+			ListOptions: listOptions,
+		})
+		if err != nil {
+			return nil, err
+		}
+		branches = append(branches, page...)
+		return resp, nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list branches: %v", err)
+	}
+
+	cutoff := time.Now().AddDate(0, 0, -options.InactiveDays)
+	for _, branch := range branches {
+		if branch.Default || branch.Protected || openSources[branch.Name] {
+			continue
+		}
+		if options.Matcher != nil && !options.Matcher.Match(branch.Name) {
+			continue
+		}
+
+		reason := ""
+		switch {
+		case options.Merged && branch.Merged:
+			reason = "merged"
+		case options.InactiveDays > 0 && branch.Commit != nil && branch.Commit.CommittedDate != nil &&
+			branch.Commit.CommittedDate.Before(cutoff):
+			reason = "no commits since " + formatTime(branch.Commit.CommittedDate)
+		}
+		if reason == "" {
+			continue
+		}
+
+		sha := ""
+		if branch.Commit != nil {
+			sha = branch.Commit.ID
+		}
+
+		if options.DryRun {
+			report.AddRow(project.PathWithNamespace, branch.Name, sha, reason, "dry run")
+			continue
+		}
+
+		// Record the branch before deleting it, and do not delete what was not recorded
+		if err := report.WriteRow(project.PathWithNamespace, branch.Name, sha, reason, "deleting"); err != nil {
+			return err
+		}
+		if _, err := client.Branches.DeleteBranch(project.ID, branch.Name); err != nil {
+			report.ReplaceLastRow(project.PathWithNamespace, branch.Name, sha, reason, err.Error())
+			continue
+		}
+		report.ReplaceLastRow(project.PathWithNamespace, branch.Name, sha, reason, "deleted")
+	}
+	return nil
+}
+
+// openMergeRequestSources returns the source branches of the open merge requests.
+func openMergeRequestSources(client *gitlab.Client, projectID int) (map[string]bool, error) {
+	sources := map[string]bool{}
+	err := Paginate(client, projectID, func(options gitlab.ListOptions) (*gitlab.Response, error) {
+		mergeRequests, resp, err := client.MergeRequests.ListProjectMergeRequests(projectID, &gitlab.ListProjectMergeRequestsOptions{
+			ListOptions: options,
+			State:       gitlab.String("opened"),
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, mergeRequest := range mergeRequests {
+			sources[mergeRequest.SourceBranch] = true
+		}
+		return resp, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list open merge requests: %v", err)
+	}
+	return sources, nil
+}
@@ -0,0 +1,157 @@
+package gitlabapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/xanzy/go-gitlab"
+)
+
+// SyncFile maps a local template file to a path in the repository.
+type SyncFile struct {
+	Source string `json:"source"` // Local template file
+	Path   string `json:"path"`   // Path of the file in the repository
+	Mode   string `json:"mode"`   // How the template is merged into the file, defaults to overwrite
+}
+
+// SyncConfig describes the files to keep in sync across projects.
+type SyncConfig struct {
+	BaseBranch    string     `json:"base_branch"` // Defaults to the project's default branch
+	Branch        string     `json:"branch"`
+	CommitMessage string     `json:"commit_message"`
+	Title         string     `json:"title"`
+	Files         []SyncFile `json:"files"`
+}
+
+// FileTemplateData is the data available to synced file templates.
+type FileTemplateData struct {
+	*gitlab.Project
+	Date string // Current date as YYYY-MM-DD
+}
+
+// LoadSyncConfig reads a sync configuration from a JSON file and fills in defaults.
+func LoadSyncConfig(configPath string) (SyncConfig, error) {
+	content, err := ioutil.ReadFile(configPath)
+	if err != nil {
+		return SyncConfig{}, err
+	}
+
+	var config SyncConfig
+	if err := json.Unmarshal(content, &config); err != nil {
+		return SyncConfig{}, fmt.Errorf("failed to parse sync config %s: %v", configPath, err)
+	}
+	if len(config.Files) == 0 {
+		return SyncConfig{}, fmt.Errorf("sync config %s lists no files", configPath)
+	}
+
+	for _, file := range config.Files {
+		switch file.Mode {
+		case "", ModeOverwrite, ModeBlock, ModeLines, ModeYAML, ModeJSON:
+		default:
+			return SyncConfig{}, fmt.Errorf("unknown merge mode %q for %s", file.Mode, file.Path)
+		}
+	}
+
+	if config.Branch == "" {
+		config.Branch = "feature/sync-files"
+	}
+	if config.CommitMessage == "" {
+		config.CommitMessage = "Sync managed files"
+	}
+	if config.Title == "" {
+		config.Title = config.CommitMessage
+	}
+	return config, nil
+}
+
+// SyncFiles renders the configured templates for the project, merges them into the
+// current files according to their mode and commits every file whose content differs
+// in a single commit with a merge request. No branch or merge request is created when
+// all files are already up to date.
+func SyncFiles(client *gitlab.Client, project *gitlab.Project, config SyncConfig) error {
+	baseBranch := config.BaseBranch
+	if baseBranch == "" {
+		baseBranch = project.DefaultBranch
+	}
+
+	data := FileTemplateData{
+		Project: project,
+		Date:    time.Now().Format("2006-01-02"),
+	}
+
+	var actions []*gitlab.CommitActionOptions
+	var changed []string
+	for _, file := range config.Files {
+		content, err := renderFileTemplate(file.Source, data)
+		if err != nil {
+			return err
+		}
+
+		current, exists, err := getFileContent(client, project.ID, file.Path, baseBranch)
+		if err != nil {
+			return err
+		}
+		content, err = mergeManagedFile(file.Mode, current, content)
+		if err != nil {
+			return fmt.Errorf("failed to merge %s: %v", file.Path, err)
+		}
+		if exists && bytes.Equal(current, content) {
+			continue
+		}
+
+		action := gitlab.FileCreate
+		if exists {
+			action = gitlab.FileUpdate
+		}
+		actions = append(actions, &gitlab.CommitActionOptions{
+			Action:   gitlab.FileAction(action),
+			FilePath: gitlab.String(file.Path),
+			Content:  gitlab.String(string(content)),
+		})
+		changed = append(changed, file.Path)
+	}
+
+	if len(actions) == 0 {
+		fmt.Printf("All synced files are up to date for project %s\n", project.PathWithNamespace)
+		return nil
+	}
+
+	var description strings.Builder
+	description.WriteString("Updates the following managed files:\n\n")
+	for _, path := range changed {
+		fmt.Fprintf(&description, "- `%s`\n", path)
+	}
+
+	return CommitAndCreateMergeRequest(client, project.ID, ChangeRequest{
+		BaseBranch:    baseBranch,
+		Branch:        config.Branch,
+		CommitMessage: config.CommitMessage,
+		Title:         config.Title,
+		Description:   description.String(),
+		Actions:       actions,
+	})
+}
+
+// renderFileTemplate renders the local template file with the given data.
+func renderFileTemplate(source string, data FileTemplateData) ([]byte, error) {
+	content, err := ioutil.ReadFile(source)
+	if err != nil {
+		return nil, err
+	}
+
+	tmpl, err := template.New(source).Option("missingkey=error").Parse(string(content))
+	if err != nil {
+		return nil, fmt.Errorf("invalid template %s: %v", source, err)
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return nil, fmt.Errorf("failed to render template %s: %v", source, err)
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,230 @@
+package gitlabapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/xanzy/go-gitlab"
+)
+
+// LabelSpec is a label of the canonical label set. Labels named in PreviousNames are
+// renamed to Name, which keeps them on the issues and merge requests that use them.
+type LabelSpec struct {
+	Name          string   `json:"name"`
+	Color         string   `json:"color"`
+	Description   string   `json:"description"`
+	PreviousNames []string `json:"previous_names"`
+}
+
+// LabelSyncOptions configures how labels are synced.
+type LabelSyncOptions struct {
+	Labels      []LabelSpec
+	DeleteExtra bool // Delete project labels that are not in the canonical set
+	DryRun      bool
+}
+
+// LoadLabelSpecs reads the canonical label set from a JSON file.
+func LoadLabelSpecs(path string) ([]LabelSpec, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var labels []LabelSpec
+	if err := json.Unmarshal(content, &labels); err != nil {
+		return nil, fmt.Errorf("failed to parse labels file %s: %v", path, err)
+	}
+	for _, label := range labels {
+		if label.Name == "" || label.Color == "" {
+			return nil, fmt.Errorf("labels file %s contains a label without name or color", path)
+		}
+	}
+	return labels, nil
+}
+
+// NewLabelReport returns an empty report with the label sync columns.
+func NewLabelReport() *Report {
+	return &Report{Headers: []string{"Project", "Label", "Change", "Result"}}
+}
+
+// SyncLabels makes the project labels match the canonical set: missing labels are
+// created, color and description drift is updated and previous names are renamed.
+// Labels outside the set are only deleted with options.DeleteExtra. Group labels are
+// not touched.
+func SyncLabels(client *gitlab.Client, project *gitlab.Project, options LabelSyncOptions, report *Report) error {
+	current, err := listProjectLabels(client, project.ID)
+	if err != nil {
+		return err
+	}
+
+	byName := map[string]*gitlab.Label{}
+	for _, label := range current {
+		byName[label.Name] = label
+	}
+
+	apply := func(label, change string, f func() error) {
+		result := "ok"
+		if options.DryRun {
+			result = "dry run"
+		} else if err := f(); err != nil {
+			result = err.Error()
+		}
+		report.AddRow(project.PathWithNamespace, label, change, result)
+	}
+
+	managed := map[string]bool{}
+	for _, spec := range options.Labels {
+		spec := spec
+		managed[spec.Name] = true
+		for _, name := range spec.PreviousNames {
+			managed[name] = true
+		}
+
+		existing := byName[spec.Name]
+		if existing == nil {
+			// Rename a label with a previous name if there is one
+			for _, name := range spec.PreviousNames {
+				if old := byName[name]; old != nil {
+					existing = old
+					break
+				}
+			}
+		}
+
+		if existing == nil {
+			apply(spec.Name, "create", func() error {
+				_, _, err := client.Labels.CreateLabel(project.ID, &gitlab.CreateLabelOptions{
+					Name:        gitlab.String(spec.Name),
+					Color:       gitlab.String(spec.Color),
+					Description: gitlab.String(spec.Description),
+				})
+				return err
+			})
+			continue
+		}
+
+		var changes []string
+		if existing.Name != spec.Name {
+			changes = append(changes, fmt.Sprintf("rename from %s", existing.Name))
+		}
+		if normalizeColor(existing.Color) != normalizeColor(spec.Color) {
+			changes = append(changes, fmt.Sprintf("color %s -> %s", existing.Color, spec.Color))
+		}
+		if existing.Description != spec.Description {
+			changes = append(changes, "description")
+		}
+		if len(changes) == 0 {
+			continue
+		}
+
+		oldName := existing.Name
+		apply(spec.Name, "update "+strings.Join(changes, ", "), func() error {
+			_, _, err := client.Labels.UpdateLabel(project.ID, &gitlab.UpdateLabelOptions{
+				Name:        gitlab.String(oldName),
+				NewName:     newLabelName(oldName, spec.Name),
+				Color:       gitlab.String(spec.Color),
+				Description: gitlab.String(spec.Description),
+			})
+			return err
+		})
+	}
+
+	if !options.DeleteExtra {
+		return nil
+	}
+	for _, label := range current {
+		if managed[label.Name] {
+			continue
+		}
+		name := label.Name
+		apply(name, "delete", func() error {
+			_, err := client.Labels.DeleteLabel(project.ID, name, nil)
+			return err
+		})
+	}
+	return nil
+}
+
+// newLabelName returns the new name if the label is renamed, or nil.
+func newLabelName(oldName, newName string) *string {
+	if oldName == newName {
+		return nil
+	}
+	return gitlab.String(newName)
+}
+
+// listProjectLabels lists the labels defined on the project itself.
+func listProjectLabels(client *gitlab.Client, projectID int) ([]*gitlab.Label, error) {
+	var labels []*gitlab.Label
+	err := Paginate(client, projectID, func(options gitlab.ListOptions) (*gitlab.Response, error) {
+		page, resp, err := client.Labels.ListLabels(projectID, &gitlab.ListLabelsOptions{
+			ListOptions:           options,
+			IncludeAncestorGroups: gitlab.Bool(false),
+		})
+		if err != nil {
+			return nil, err
+		}
+		labels = append(labels, page...)
+		return resp, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list labels for project %d: %v", projectID, err)
+	}
+	return labels, nil
+}
+
+// namedColors maps the CSS color names GitLab accepts to the hex value it stores.
+var namedColors = map[string]string{
+	"aliceblue": "#f0f8ff", "antiquewhite": "#faebd7", "aqua": "#00ffff", "aquamarine": "#7fffd4",
+	"azure": "#f0ffff", "beige": "#f5f5dc", "bisque": "#ffe4c4", "black": "#000000",
+	"blanchedalmond": "#ffebcd", "blue": "#0000ff", "blueviolet": "#8a2be2", "brown": "#a52a2a",
+	"burlywood": "#deb887", "cadetblue": "#5f9ea0", "chartreuse": "#7fff00", "chocolate": "#d2691e",
+	"coral": "#ff7f50", "cornflowerblue": "#6495ed", "cornsilk": "#fff8dc", "crimson": "#dc143c",
+	"cyan": "#00ffff", "darkblue": "#00008b", "darkcyan": "#008b8b", "darkgoldenrod": "#b8860b",
+	"darkgray": "#a9a9a9", "darkgreen": "#006400", "darkgrey": "#a9a9a9", "darkkhaki": "#bdb76b",
+	"darkmagenta": "#8b008b", "darkolivegreen": "#556b2f", "darkorange": "#ff8c00", "darkorchid": "#9932cc",
+	"darkred": "#8b0000", "darksalmon": "#e9967a", "darkseagreen": "#8fbc8f", "darkslateblue": "#483d8b",
+	"darkslategray": "#2f4f4f", "darkslategrey": "#2f4f4f", "darkturquoise": "#00ced1", "darkviolet": "#9400d3",
+	"deeppink": "#ff1493", "deepskyblue": "#00bfff", "dimgray": "#696969", "dimgrey": "#696969",
+	"dodgerblue": "#1e90ff", "firebrick": "#b22222", "floralwhite": "#fffaf0", "forestgreen": "#228b22",
+	"fuchsia": "#ff00ff", "gainsboro": "#dcdcdc", "ghostwhite": "#f8f8ff", "gold": "#ffd700",
+	"goldenrod": "#daa520", "gray": "#808080", "green": "#008000", "greenyellow": "#adff2f",
+	"grey": "#808080", "honeydew": "#f0fff0", "hotpink": "#ff69b4", "indianred": "#cd5c5c",
+	"indigo": "#4b0082", "ivory": "#fffff0", "khaki": "#f0e68c", "lavender": "#e6e6fa",
+	"lavenderblush": "#fff0f5", "lawngreen": "#7cfc00", "lemonchiffon": "#fffacd", "lightblue": "#add8e6",
+	"lightcoral": "#f08080", "lightcyan": "#e0ffff", "lightgoldenrodyellow": "#fafad2", "lightgray": "#d3d3d3",
+	"lightgreen": "#90ee90", "lightgrey": "#d3d3d3", "lightpink": "#ffb6c1", "lightsalmon": "#ffa07a",
+	"lightseagreen": "#20b2aa", "lightskyblue": "#87cefa", "lightslategray": "#778899", "lightslategrey": "#778899",
+	"lightsteelblue": "#b0c4de", "lightyellow": "#ffffe0", "lime": "#00ff00", "limegreen": "#32cd32",
+	"linen": "#faf0e6", "magenta": "#ff00ff", "maroon": "#800000", "mediumaquamarine": "#66cdaa",
+	"mediumblue": "#0000cd", "mediumorchid": "#ba55d3", "mediumpurple": "#9370db", "mediumseagreen": "#3cb371",
+	"mediumslateblue": "#7b68ee", "mediumspringgreen": "#00fa9a", "mediumturquoise": "#48d1cc", "mediumvioletred": "#c71585",
+	"midnightblue": "#191970", "mintcream": "#f5fffa", "mistyrose": "#ffe4e1", "moccasin": "#ffe4b5",
+	"navajowhite": "#ffdead", "navy": "#000080", "oldlace": "#fdf5e6", "olive": "#808000",
+	"olivedrab": "#6b8e23", "orange": "#ffa500", "orangered": "#ff4500", "orchid": "#da70d6",
+	"palegoldenrod": "#eee8aa", "palegreen": "#98fb98", "paleturquoise": "#afeeee", "palevioletred": "#db7093",
+	"papayawhip": "#ffefd5", "peachpuff": "#ffdab9", "peru": "#cd853f", "pink": "#ffc0cb",
+	"plum": "#dda0dd", "powderblue": "#b0e0e6", "purple": "#800080", "rebeccapurple": "#663399",
+	"red": "#ff0000", "rosybrown": "#bc8f8f", "royalblue": "#4169e1", "saddlebrown": "#8b4513",
+	"salmon": "#fa8072", "sandybrown": "#f4a460", "seagreen": "#2e8b57", "seashell": "#fff5ee",
+	"sienna": "#a0522d", "silver": "#c0c0c0", "skyblue": "#87ceeb", "slateblue": "#6a5acd",
+	"slategray": "#708090", "slategrey": "#708090", "snow": "#fffafa", "springgreen": "#00ff7f",
+	"steelblue": "#4682b4", "tan": "#d2b48c", "teal": "#008080", "thistle": "#d8bfd8",
+	"tomato": "#ff6347", "turquoise": "#40e0d0", "violet": "#ee82ee", "wheat": "#f5deb3",
+	"white": "#ffffff", "whitesmoke": "#f5f5f5", "yellow": "#ffff00", "yellowgreen": "#9acd32",
+}
+
+// normalizeColor returns the color as lowercase six-digit hex so that "#FFF", "#ffffff"
+// and "white" compare equal. Unknown values are only trimmed and lowercased.
+func normalizeColor(color string) string {
+	color = strings.ToLower(strings.TrimSpace(color))
+	if hex, ok := namedColors[color]; ok {
+		return hex
+	}
+	if len(color) == 4 && color[0] == '#' {
+		return "#" + strings.Repeat(color[1:2], 2) + strings.Repeat(color[2:3], 2) + strings.Repeat(color[3:4], 2)
+	}
+	return color
+}
@@ -0,0 +1,242 @@
+package gitlabapi
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/xanzy/go-gitlab"
+)
+
+// Operations on project webhooks.
+const (
+	WebhooksApply  = "apply"  // Add the webhook or update it when it differs
+	WebhooksRemove = "remove" // Remove the webhook
+	WebhooksAudit  = "audit"  // Report webhooks to unknown hosts or without SSL verification
+)
+
+// webhookEvents are the event names accepted in WebhookSpec.Events.
+var webhookEvents = []string{
+	"push", "tag_push", "merge_requests", "issues", "confidential_issues", "note",
+	"confidential_note", "job", "pipeline", "wiki_page", "deployment", "releases",
+}
+
+// WebhookSpec describes a project webhook. Webhooks are identified by their URL.
+type WebhookSpec struct {
+	URL                   string
+	Events                []string // Names from webhookEvents, e.g. push or merge_requests
+	BranchFilter          string   // Push events branch filter, optional
+	EnableSSLVerification bool
+	Token                 string // Secret token; GitLab never returns it, so it is always sent when set
+}
+
+// ParseWebhookEvents parses and validates a comma-separated list of event names.
+func ParseWebhookEvents(csv string) ([]string, error) {
+	events := ParsePatterns(csv)
+	for _, event := range events {
+		if !containsString(webhookEvents, event) {
+			return nil, fmt.Errorf("unknown webhook event %s (valid: %s)", event, strings.Join(webhookEvents, ", "))
+		}
+	}
+	return events, nil
+}
+
+// NewWebhookReport returns an empty report with the webhook columns.
+func NewWebhookReport() *Report {
+	return &Report{Headers: []string{"Project", "URL", "Events", "SSL Verification", "Result"}}
+}
+
+// ManageWebhook adds, updates or removes the webhook on the project. Applying is
+// idempotent: an existing hook with the same URL is only edited when its events, branch
+// filter or SSL verification differ. In dry-run mode changes are only reported.
+func ManageWebhook(client *gitlab.Client, project *gitlab.Project, operation string, spec WebhookSpec, dryRun bool, report *Report) error {
+	hooks, err := listProjectHooks(client, project.ID)
+	if err != nil {
+		return err
+	}
+
+	var existing *gitlab.ProjectHook
+	for _, hook := range hooks {
+		if hook.URL == spec.URL {
+			existing = hook
+			break
+		}
+	}
+
+	var change string
+	var apply func() error
+	switch operation {
+	case WebhooksApply:
+		options := webhookOptions(spec)
+		switch {
+		case existing == nil:
+			change = "add"
+			apply = func() error {
+				_, _, err := client.Projects.AddProjectHook(project.ID, options)
+				return err
+			}
+		default:
+			fields := webhookChanges(existing, spec)
+			if len(fields) == 0 {
+				return nil
+			}
+			change = "update " + strings.Join(fields, ", ")
+			hookID := existing.ID
+			apply = func() error {
+				edit := gitlab.EditProjectHookOptions(*options)
+				_, _, err := client.Projects.EditProjectHook(project.ID, hookID, &edit)
+				return err
+			}
+		}
+	case WebhooksRemove:
+		if existing == nil {
+			return nil
+		}
+		change = "remove"
+		hookID := existing.ID
+		apply = func() error {
+			_, err := client.Projects.DeleteProjectHook(project.ID, hookID)
+			return err
+		}
+	default:
+		return fmt.Errorf("unknown webhook operation: %s", operation)
+	}
+
+	result := "ok"
+	if dryRun {
+		result = "dry run"
+	} else if err := apply(); err != nil {
+		result = err.Error()
+	}
+	report.AddRow(project.PathWithNamespace, spec.URL, strings.Join(spec.Events, ", "),
+		strconv.FormatBool(spec.EnableSSLVerification), change+": "+result)
+	return nil
+}
+
+// AuditWebhooks reports the project webhooks that point to a host outside allowedHosts
+// or have SSL verification disabled. Nothing is modified.
+func AuditWebhooks(client *gitlab.Client, project *gitlab.Project, allowedHosts []string, report *Report) error {
+	hooks, err := listProjectHooks(client, project.ID)
+	if err != nil {
+		return err
+	}
+
+	for _, hook := range hooks {
+		var flags []string
+		host := ""
+		if u, err := url.Parse(hook.URL); err == nil {
+			host = u.Hostname()
+		}
+		if !containsString(allowedHosts, host) {
+			flags = append(flags, "unknown host "+host)
+		}
+		if !hook.EnableSSLVerification {
+			flags = append(flags, "SSL verification disabled")
+		}
+		if len(flags) == 0 {
+			continue
+		}
+		report.AddRow(project.PathWithNamespace, hook.URL, strings.Join(hookEvents(hook), ", "),
+			strconv.FormatBool(hook.EnableSSLVerification), strings.Join(flags, "; "))
+	}
+	return nil
+}
+
+// webhookOptions returns the hook options for the spec, with every event explicitly
+// enabled or disabled.
+func webhookOptions(spec WebhookSpec) *gitlab.AddProjectHookOptions {
+	enabled := func(event string) *bool {
+		return gitlab.Bool(containsString(spec.Events, event))
+	}
+
+	options := &gitlab.AddProjectHookOptions{
+		URL:                      gitlab.String(spec.URL),
+		PushEvents:               enabled("push"),
+		PushEventsBranchFilter:   gitlab.String(spec.BranchFilter),
+		TagPushEvents:            enabled("tag_push"),
+		MergeRequestsEvents:      enabled("merge_requests"),
+		IssuesEvents:             enabled("issues"),
+		ConfidentialIssuesEvents: enabled("confidential_issues"),
+		NoteEvents:               enabled("note"),
+		ConfidentialNoteEvents:   enabled("confidential_note"),
+		JobEvents:                enabled("job"),
+		PipelineEvents:           enabled("pipeline"),
+		WikiPageEvents:           enabled("wiki_page"),
+		DeploymentEvents:         enabled("deployment"),
+		ReleasesEvents:           enabled("releases"),
+		EnableSSLVerification:    gitlab.Bool(spec.EnableSSLVerification),
+	}
+	if spec.Token != "" {
+		options.Token = gitlab.String(spec.Token)
+	}
+	return options
+}
+
+// hookEvents returns the names of the events enabled on the hook, sorted.
+func hookEvents(hook *gitlab.ProjectHook) []string {
+	enabled := map[string]bool{
+		"push":                hook.PushEvents,
+		"tag_push":            hook.TagPushEvents,
+		"merge_requests":      hook.MergeRequestsEvents,
+		"issues":              hook.IssuesEvents,
+		"confidential_issues": hook.ConfidentialIssuesEvents,
+		"note":                hook.NoteEvents,
+		"confidential_note":   hook.ConfidentialNoteEvents,
+		"job":                 hook.JobEvents,
+		"pipeline":            hook.PipelineEvents,
+		"wiki_page":           hook.WikiPageEvents,
+		"deployment":          hook.DeploymentEvents,
+		"releases":            hook.ReleasesEvents,
+	}
+
+	var events []string
+	for event, on := range enabled {
+		if on {
+			events = append(events, event)
+		}
+	}
+	sort.Strings(events)
+	return events
+}
+
+// webhookChanges returns the names of the hook settings that differ from the spec. The
+// secret token cannot be compared, so a token in the spec is always updated.
+func webhookChanges(hook *gitlab.ProjectHook, spec WebhookSpec) []string {
+	var fields []string
+
+	desired := append([]string{}, spec.Events...)
+	sort.Strings(desired)
+	if strings.Join(hookEvents(hook), ",") != strings.Join(desired, ",") {
+		fields = append(fields, "events")
+	}
+	if hook.PushEventsBranchFilter != spec.BranchFilter {
+		fields = append(fields, "branch filter")
+	}
+	if hook.EnableSSLVerification != spec.EnableSSLVerification {
+		fields = append(fields, "SSL verification")
+	}
+	if spec.Token != "" {
+		fields = append(fields, "token")
+	}
+	return fields
+}
+
+// listProjectHooks lists the webhooks of the project.
+func listProjectHooks(client *gitlab.Client, projectID int) ([]*gitlab.ProjectHook, error) {
+	var hooks []*gitlab.ProjectHook
+	err := Paginate(client, projectID, func(options gitlab.ListOptions) (*gitlab.Response, error) {
+		listOptions := gitlab.ListProjectHooksOptions(options)
+		page, resp, err := client.Projects.ListProjectHooks(projectID, &listOptions)
+		if err != nil {
+			return nil, err
+		}
+		hooks = append(hooks, page...)
+		return resp, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list webhooks for project %d: %v", projectID, err)
+	}
+	return hooks, nil
+}
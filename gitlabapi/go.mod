@@ -2,17 +2,19 @@ module gitlabapi
 
 go 1.22.2
 
+require (
+	github.com/hashicorp/go-retryablehttp v0.7.6
+	github.com/xanzy/go-gitlab v0.105.0
+	gopkg.in/yaml.v3 v3.0.1
+)
 
 require (
 	github.com/golang/protobuf v1.5.4 // indirect
 	github.com/google/go-querystring v1.1.0 // indirect
 	github.com/hashicorp/go-cleanhttp v0.5.2 // indirect
-	github.com/hashicorp/go-retryablehttp v0.7.6 // indirect
-	github.com/xanzy/go-gitlab v0.105.0 // indirect
 	golang.org/x/net v0.25.0 // indirect
 	golang.org/x/oauth2 v0.20.0 // indirect
 	golang.org/x/time v0.5.0 // indirect
 	google.golang.org/appengine v1.6.8 // indirect
 	google.golang.org/protobuf v1.34.1 // indirect
-
 )
@@ -15,4 +15,5 @@ require (
 	github.com/hashicorp/go-retryablehttp v0.7.6 // indirect
 	golang.org/x/oauth2 v0.20.0 // indirect
 	golang.org/x/time v0.5.0 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"regexp"
+	"strconv"
 	"strings"
 	"time"
 
@@ -18,6 +20,24 @@ const (
 	perPage   = 20              // Number of projects to process per page
 )
 
+// reportActions are the actions whose results are collected in a report that is written
+// in the chosen format at the end. Actions that write their own CSV file are not listed.
+var reportActions = map[string]bool{
+	"audit":                  true,
+	"trigger-pipeline":       true,
+	"pipeline-health":        true,
+	"retry-pipelines":        true,
+	"cancel-pipelines":       true,
+	"variables":              true,
+	"sync-labels":            true,
+	"members":                true,
+	"access-review":          true,
+	"webhooks":               true,
+	"protection-report":      true,
+	"create-release":         true,
+	"migrate-default-branch": true,
+}
+
 func main() {
 	client, err := gitlabapi.NewGitLabClient()
 	if err != nil {
@@ -56,7 +76,7 @@ func main() {
 	groupID := groups[0].ID
 
 	// Prompt for the action to perform
-	fmt.Print("Enter action (create-gitignore/accept-merge-request/delete-car-files/create-branch/trigger-pipeline/create-mr/close-mr/change-project-rules): ")
+	fmt.Print("Enter action (create-gitignore/sync-files/accept-merge-request/delete-car-files/purge-files/replace-content/audit/create-branch/trigger-pipeline/pipeline-health/retry-pipelines/cancel-pipelines/variables/sync-labels/members/access-review/webhooks/protect-branches/unprotect-branches/protect-tags/unprotect-tags/protection-report/delete-stale-branches/create-release/reorganize/migrate-default-branch/create-mr/close-mr/change-project-rules/reconcile-settings/approvals/check): ")
 	action, err := reader.ReadString('\n')
 	if err != nil {
 		log.Fatalf("Failed to read action: %v", err)
@@ -72,6 +92,47 @@ func main() {
 	var targetBranch string
 	var closeBranch string
 	var newRegex string
+	var pushRules *gitlab.EditProjectPushRuleOptions
+	var projectSettings *gitlabapi.ProjectSettings
+	var approvalConfig *gitlabapi.ApprovalConfig
+	var policy *gitlabapi.Policy
+	var junitPath string
+	matrix := &gitlabapi.ComplianceMatrix{}
+	var dryRun bool
+	var failed bool
+	var pipelineOptions gitlabapi.PipelineOptions
+	var waitPipelines bool
+	var pipelineTimeout time.Duration
+	var pipelineResults []*gitlabapi.PipelineResult
+	var healthRef string
+	healthDepth := 10
+	var pipelineFilter gitlabapi.PipelineFilter
+	var variablesOperation string
+	var variableSpecs []*gitlabapi.VariableSpec
+	var groupLevel bool
+	var labelOptions gitlabapi.LabelSyncOptions
+	var memberChange gitlabapi.MemberChange
+	var reviewer *gitlabapi.AccessReviewer
+	var webhookOperation string
+	var webhook gitlabapi.WebhookSpec
+	var allowedHosts []string
+	var protectPatterns []string
+	var tagCreateLevel gitlab.AccessLevelValue
+	var staleOptions gitlabapi.StaleBranchOptions
+	var releaseOptions gitlabapi.ReleaseOptions
+	var reorganizeOptions gitlabapi.ReorganizeOptions
+	var migration gitlabapi.DefaultBranchMigration
+	var describeMR bool
+	protection := gitlabapi.DefaultBranchProtection()
+	var refSpec gitlabapi.RefSpec
+	var versionFile string
+	var syncConfig gitlabapi.SyncConfig
+	var purgeOptions gitlabapi.PurgeOptions
+	var replaceOptions gitlabapi.ReplaceOptions
+	var auditOptions gitlabapi.AuditOptions
+	var reportFormat string
+	var reportPath string
+	report := &gitlabapi.Report{}
 
 	if action == "accept-merge-request" {
 		// Prompt for the branch name prefix
@@ -109,18 +170,447 @@ func main() {
 			log.Fatalf("Failed to read branch name prefix: %v", err)
 		}
 		targetBranch = strings.TrimSpace(targetBranch)
+
+		// Prompt for generating the description from the commit log
+		describeMR = promptYesNo(reader, "Generate description from commits and changed files? (y/n): ")
 	}
 
 	if action == "change-project-rules" {
+		// Prompt for the push rules file, falling back to only the branch name regex
+		rulesPath := prompt(reader, "Enter push rules file (empty to only set the branch name regex): ")
+		if rulesPath != "" {
+			pushRules, err = gitlabapi.LoadPushRules(rulesPath)
+			if err != nil {
+				log.Fatalf("Failed to load push rules: %v", err)
+			}
+		} else {
+			fmt.Print("Enter future regex: ")
+			newRegex, err = reader.ReadString('\n')
+			if err != nil {
+				log.Fatalf("Failed to read branch name regex: %v", err)
+			}
+			newRegex = strings.TrimSpace(newRegex)
+			pushRules = &gitlab.EditProjectPushRuleOptions{
+				BranchNameRegex: gitlab.String(newRegex),
+			}
+		}
+		dryRun = promptYesNo(reader, "Dry run? (y/n): ")
+	}
 
-		// Prompt for the target branch name prefix
-		fmt.Print("Enter future regex: ")
-		newRegex, err = reader.ReadString('\n')
+	if action == "reconcile-settings" {
+		// Prompt for the desired settings file
+		settingsPath := prompt(reader, "Enter the desired settings file: ")
+		projectSettings, err = gitlabapi.LoadProjectSettings(settingsPath)
 		if err != nil {
-			log.Fatalf("Failed to read branch name prefix: %v", err)
+			log.Fatalf("Failed to load project settings: %v", err)
+		}
+		dryRun = promptYesNo(reader, "Dry run (only report drift)? (y/n): ")
+	}
+
+	if action == "approvals" {
+		// Prompt for the approval settings and rules file
+		approvalsPath := prompt(reader, "Enter the approval config file: ")
+		approvalConfig, err = gitlabapi.LoadApprovalConfig(approvalsPath)
+		if err != nil {
+			log.Fatalf("Failed to load approval config: %v", err)
+		}
+		dryRun = promptYesNo(reader, "Dry run (only report drift)? (y/n): ")
+	}
+
+	if action == "check" {
+		// Prompt for the policy and the report outputs
+		policyPath := prompt(reader, "Enter the policy file: ")
+		policy, err = gitlabapi.LoadPolicy(policyPath)
+		if err != nil {
+			log.Fatalf("Failed to load policy: %v", err)
+		}
+		reportFormat = prompt(reader, "Enter matrix format (markdown/html, default markdown): ")
+		if err := gitlabapi.ValidateMatrixFormat(reportFormat); err != nil {
+			log.Fatalf("Invalid matrix format: %v", err)
+		}
+		reportPath = prompt(reader, "Enter matrix file (empty for stdout): ")
+		junitPath = prompt(reader, "Enter JUnit XML file (optional): ")
+	}
+
+	if action == "sync-files" {
+		// Prompt for the sync configuration
+		configPath := prompt(reader, "Enter the sync config file: ")
+		syncConfig, err = gitlabapi.LoadSyncConfig(configPath)
+		if err != nil {
+			log.Fatalf("Failed to load sync config: %v", err)
+		}
+	}
+
+	if action == "purge-files" {
+		// Prompt for the files to purge
+		include := gitlabapi.ParsePatterns(prompt(reader, "Enter include patterns (comma-separated globs or re:<regex>): "))
+		exclude := gitlabapi.ParsePatterns(prompt(reader, "Enter exclude patterns (comma-separated, optional): "))
+		prefix := prompt(reader, "Enter path prefix (optional): ")
+		purgeOptions.Matcher, err = gitlabapi.NewPathMatcher(include, exclude, prefix)
+		if err != nil {
+			log.Fatalf("Invalid patterns: %v", err)
+		}
+
+		if minSize := prompt(reader, "Enter the minimum file size in bytes (optional, default any size): "); minSize != "" {
+			purgeOptions.MinSize, err = strconv.Atoi(minSize)
+			if err != nil {
+				log.Fatalf("Invalid size: %v", err)
+			}
+		}
+
+		purgeOptions.BaseBranch = prompt(reader, "Enter the base branch (default: project default branch): ")
+		purgeOptions.Branch = prompt(reader, "Enter the branch name (default: feature/purge-files): ")
+		if purgeOptions.Branch == "" {
+			purgeOptions.Branch = "feature/purge-files"
+		}
+	}
+
+	if action == "replace-content" {
+		// Prompt for the files to search
+		include := gitlabapi.ParsePatterns(prompt(reader, "Enter file patterns (comma-separated globs or re:<regex>): "))
+		replaceOptions.Matcher, err = gitlabapi.NewPathMatcher(include, nil, "")
+		if err != nil {
+			log.Fatalf("Invalid patterns: %v", err)
+		}
+
+		// Prompt for the replacements until an empty regex is entered
+		for {
+			pattern := prompt(reader, "Enter regex to replace (empty to finish): ")
+			if pattern == "" {
+				break
+			}
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				log.Fatalf("Invalid regex: %v", err)
+			}
+			replacement := prompt(reader, "Enter replacement ($1 refers to capture groups): ")
+			replaceOptions.Replacements = append(replaceOptions.Replacements, gitlabapi.Replacement{
+				Pattern:     re,
+				Replacement: replacement,
+			})
+		}
+		if len(replaceOptions.Replacements) == 0 {
+			log.Fatalf("At least one replacement is required")
+		}
+
+		replaceOptions.BaseBranch = prompt(reader, "Enter the base branch (default: project default branch): ")
+		replaceOptions.Branch = prompt(reader, "Enter the branch name (default: feature/replace-content): ")
+		if replaceOptions.Branch == "" {
+			replaceOptions.Branch = "feature/replace-content"
+		}
+		replaceOptions.DryRun = promptYesNo(reader, "Dry run? (y/n): ")
+	}
+
+	if action == "audit" {
+		// Prompt for the files and content to look for
+		include := gitlabapi.ParsePatterns(prompt(reader, "Enter file patterns (comma-separated globs or re:<regex>, default **): "))
+		if len(include) == 0 {
+			include = []string{"**"}
+		}
+		auditOptions.Matcher, err = gitlabapi.NewPathMatcher(include, nil, "")
+		if err != nil {
+			log.Fatalf("Invalid patterns: %v", err)
+		}
+
+		if pattern := prompt(reader, "Enter content regex (empty to report matching files only): "); pattern != "" {
+			auditOptions.Content, err = regexp.Compile(pattern)
+			if err != nil {
+				log.Fatalf("Invalid regex: %v", err)
+			}
+			auditOptions.UseSearch = promptYesNo(reader, "Use the search API to find candidate files? (y/n): ")
+			if auditOptions.UseSearch {
+				auditOptions.Query = prompt(reader, "Enter the search text (default: the regex): ")
+			}
+		}
+
+		auditOptions.Ref = prompt(reader, "Enter the ref (default: project default branch): ")
+		report = gitlabapi.NewAuditReport()
+	}
+
+	if action == "pipeline-health" {
+		// Prompt for the ref and the number of pipelines to inspect
+		healthRef = prompt(reader, "Enter the ref (default: project default branch): ")
+		if depth := prompt(reader, "Enter the number of pipelines to inspect for flaky jobs (default 10): "); depth != "" {
+			healthDepth, err = strconv.Atoi(depth)
+			if err != nil || healthDepth < 1 {
+				log.Fatalf("Invalid number of pipelines: %s", depth)
+			}
+		}
+		report = gitlabapi.NewPipelineHealthReport()
+	}
+
+	if action == "retry-pipelines" || action == "cancel-pipelines" {
+		// Prompt for the pipelines to operate on
+		pipelineFilter.Ref = prompt(reader, "Enter the ref (empty for any ref): ")
+		pipelineFilter.Statuses = gitlabapi.ParsePatterns(prompt(reader, "Enter pipeline statuses (comma-separated, default failed for retry and running,pending for cancel): "))
+		hoursWindow := 24
+		if hours := prompt(reader, "Only pipelines updated in the last N hours (default 24): "); hours != "" {
+			hoursWindow, err = strconv.Atoi(hours)
+			if err != nil || hoursWindow < 1 {
+				log.Fatalf("Invalid number of hours: %s", hours)
+			}
+		}
+		after := time.Now().Add(-time.Duration(hoursWindow) * time.Hour)
+		pipelineFilter.UpdatedAfter = &after
+		dryRun = promptYesNo(reader, "Dry run (only list the pipelines)? (y/n): ")
+		report = gitlabapi.NewPipelineOperationReport()
+	}
+
+	if action == "variables" {
+		// Prompt for the operation and the variables file; values are never entered here
+		variablesOperation = prompt(reader, "Enter operation (list/diff/apply/delete): ")
+		switch variablesOperation {
+		case gitlabapi.VariablesList:
+		case gitlabapi.VariablesDiff, gitlabapi.VariablesApply, gitlabapi.VariablesDelete:
+			variablesPath := prompt(reader, "Enter the variables file: ")
+			needValues := variablesOperation != gitlabapi.VariablesDelete
+			variableSpecs, err = gitlabapi.LoadVariableSpecs(variablesPath, needValues)
+			if err != nil {
+				log.Fatalf("Failed to load variables: %v", err)
+			}
+		default:
+			log.Fatalf("Invalid operation: %s", variablesOperation)
+		}
+		if variablesOperation == gitlabapi.VariablesApply || variablesOperation == gitlabapi.VariablesDelete {
+			dryRun = promptYesNo(reader, "Dry run? (y/n): ")
+		}
+		groupLevel = prompt(reader, "Manage group or project variables? (group/project, default project): ") == "group"
+		report = gitlabapi.NewVariablesReport()
+	}
+
+	if action == "sync-labels" {
+		// Prompt for the canonical label set
+		labelsPath := prompt(reader, "Enter the labels file: ")
+		labelOptions.Labels, err = gitlabapi.LoadLabelSpecs(labelsPath)
+		if err != nil {
+			log.Fatalf("Failed to load labels: %v", err)
+		}
+		labelOptions.DeleteExtra = promptYesNo(reader, "Delete labels that are not in the file? (y/n): ")
+		labelOptions.DryRun = promptYesNo(reader, "Dry run? (y/n): ")
+		report = gitlabapi.NewLabelReport()
+	}
+
+	if action == "members" {
+		// Prompt for the membership change
+		memberChange.Operation = prompt(reader, "Enter operation (add/remove/update): ")
+		switch memberChange.Operation {
+		case gitlabapi.MembersAdd, gitlabapi.MembersUpdate:
+			memberChange.AccessLevel = promptAccessLevel(reader, "Enter access level (guest/reporter/developer/maintainer/owner, default developer): ", gitlab.DeveloperPermissions)
+			memberChange.ExpiresAt = prompt(reader, "Enter expiry date (YYYY-MM-DD, optional): ")
+		case gitlabapi.MembersRemove:
+		default:
+			log.Fatalf("Invalid operation: %s", memberChange.Operation)
+		}
+		memberChange.UserIDs = promptIDs(reader, "Enter user IDs (comma-separated, optional): ")
+		memberChange.GroupIDs = promptIDs(reader, "Enter group IDs to share with (comma-separated, optional): ")
+		dryRun = promptYesNo(reader, "Dry run? (y/n): ")
+		report = gitlabapi.NewMembersReport()
+	}
+
+	if action == "access-review" {
+		// Prompt for the policy of who may be Maintainer or Owner
+		var accessPolicy *gitlabapi.AccessPolicy
+		if policyPath := prompt(reader, "Enter the access policy file (optional): "); policyPath != "" {
+			accessPolicy, err = gitlabapi.LoadAccessPolicy(policyPath)
+			if err != nil {
+				log.Fatalf("Failed to load access policy: %v", err)
+			}
+		}
+		err = gitlabapi.UseRateLimiter(limiter, func() error {
+			var err error
+			reviewer, err = gitlabapi.NewAccessReviewer(client, groupID, accessPolicy)
+			return err
+		})
+		if err != nil {
+			log.Fatalf("Failed to prepare access review: %v", err)
+		}
+		report = gitlabapi.NewAccessReviewReport()
+	}
+
+	if action == "webhooks" {
+		// Prompt for the webhook; the secret token is read from the environment
+		webhookOperation = prompt(reader, "Enter operation (apply/remove/audit): ")
+		switch webhookOperation {
+		case gitlabapi.WebhooksApply:
+			webhook.URL = prompt(reader, "Enter the webhook URL: ")
+			webhook.Events, err = gitlabapi.ParseWebhookEvents(prompt(reader, "Enter events (comma-separated, e.g. push,merge_requests,pipeline): "))
+			if err != nil {
+				log.Fatalf("Invalid events: %v", err)
+			}
+			webhook.BranchFilter = prompt(reader, "Enter the push events branch filter (optional): ")
+			webhook.EnableSSLVerification = !promptYesNo(reader, "Disable SSL verification? (y/n): ")
+			if tokenEnv := prompt(reader, "Enter the environment variable holding the secret token (optional): "); tokenEnv != "" {
+				token, ok := os.LookupEnv(tokenEnv)
+				if !ok {
+					log.Fatalf("Environment variable %s is not set", tokenEnv)
+				}
+				webhook.Token = token
+			}
+			dryRun = promptYesNo(reader, "Dry run? (y/n): ")
+		case gitlabapi.WebhooksRemove:
+			webhook.URL = prompt(reader, "Enter the webhook URL: ")
+			dryRun = promptYesNo(reader, "Dry run? (y/n): ")
+		case gitlabapi.WebhooksAudit:
+			allowedHosts = gitlabapi.ParsePatterns(prompt(reader, "Enter the known webhook hosts (comma-separated): "))
+		default:
+			log.Fatalf("Invalid operation: %s", webhookOperation)
+		}
+		report = gitlabapi.NewWebhookReport()
+	}
+
+	if action == "protect-branches" || action == "unprotect-branches" {
+		// Prompt for the branches or wildcard patterns, e.g. release/*
+		protectPatterns = gitlabapi.ParsePatterns(prompt(reader, "Enter branch names or patterns (comma-separated, e.g. main,release/*): "))
+		if action == "protect-branches" {
+			protection = promptProtectionSettings(reader, gitlabapi.DefaultBranchProtection())
+		}
+	}
+
+	if action == "protect-tags" || action == "unprotect-tags" {
+		// Prompt for the tags or wildcard patterns, e.g. v*
+		protectPatterns = gitlabapi.ParsePatterns(prompt(reader, "Enter tag names or patterns (comma-separated, e.g. v*): "))
+		if action == "protect-tags" {
+			tagCreateLevel = promptAccessLevel(reader, "Create access level (no/developer/maintainer/admin, default maintainer): ", gitlab.MaintainerPermissions)
+		}
+	}
+
+	if action == "protection-report" {
+		report = gitlabapi.NewProtectionReport()
+	}
+
+	if action == "delete-stale-branches" {
+		// Prompt for the branches to consider and what makes them stale
+		include := gitlabapi.ParsePatterns(prompt(reader, "Enter branch patterns (comma-separated, default feature/*): "))
+		if len(include) == 0 {
+			include = []string{"feature/*"}
+		}
+		staleOptions.Matcher, err = gitlabapi.NewPathMatcher(include, nil, "")
+		if err != nil {
+			log.Fatalf("Invalid patterns: %v", err)
+		}
+		staleOptions.Merged = promptYesNo(reader, "Delete branches merged into the default branch? (y/n): ")
+		if days := prompt(reader, "Delete branches without commits for N days (optional): "); days != "" {
+			staleOptions.InactiveDays, err = strconv.Atoi(days)
+			if err != nil || staleOptions.InactiveDays < 1 {
+				log.Fatalf("Invalid number of days: %s", days)
+			}
+		}
+		if !staleOptions.Merged && staleOptions.InactiveDays == 0 {
+			log.Fatalf("Nothing to delete: choose merged branches, inactive branches or both")
+		}
+		staleOptions.DryRun = promptYesNo(reader, "Dry run? (y/n): ")
+
+		// The deleted branches are always written as CSV so they can be recreated. Each
+		// branch is written to the file before it is deleted.
+		reportPath = prompt(reader, "Enter the CSV file for deleted branches (default deleted-branches.csv): ")
+		if reportPath == "" {
+			reportPath = "deleted-branches.csv"
+		}
+		report = gitlabapi.NewStaleBranchReport()
+		if err := report.OpenJournal(reportPath); err != nil {
+			log.Fatalf("Failed to create %s: %v", reportPath, err)
 		}
-		newRegex = strings.TrimSpace(targetBranch)
+	}
+
+	if action == "create-release" {
+		// Prompt for the ref to tag and the tag name template
+		releaseOptions.Ref, err = gitlabapi.ParseRefSpec(prompt(reader, "Enter the reference (branch name, default, tag:<pattern> or sha:<mapping file>): "))
+		if err != nil {
+			log.Fatalf("Invalid reference: %v", err)
+		}
+		releaseOptions.TagTemplate = prompt(reader, "Enter the tag name (template, e.g. v{{.Version}} or release-{{.Date}}): ")
+		releaseOptions.VersionFile = prompt(reader, "Enter the version file for {{.Version}} (e.g. VERSION or package.json, optional): ")
+		releaseOptions.PreviousPattern = prompt(reader, "Enter the pattern of previous release tags for the changelog (default *): ")
+
+		// Prompt for the asset links until an empty line is entered
+		for {
+			definition := prompt(reader, "Enter asset link (name=URL template with {{.Path}} and {{.Tag}}, empty to finish): ")
+			if definition == "" {
+				break
+			}
+			name, url, ok := strings.Cut(definition, "=")
+			if !ok || name == "" || url == "" {
+				log.Fatalf("Invalid asset link: %s", definition)
+			}
+			releaseOptions.Assets = append(releaseOptions.Assets, gitlabapi.ReleaseAsset{Name: name, URL: url})
+		}
+
+		releaseOptions.RequirePipeline = promptYesNo(reader, "Only release when the pipeline of the ref passed? (y/n): ")
+		releaseOptions.DryRun = promptYesNo(reader, "Dry run? (y/n): ")
+		report = gitlabapi.NewReleaseReport()
+	}
+
+	if action == "reorganize" {
+		// Prompt for the operation and the projects it applies to
+		reorganizeOptions.Operation = prompt(reader, "Enter operation (archive/unarchive/transfer/rename): ")
+		if include := gitlabapi.ParsePatterns(prompt(reader, "Enter project path patterns (comma-separated, e.g. group/legacy-*; required for transfer): ")); len(include) > 0 {
+			reorganizeOptions.Matcher, err = gitlabapi.NewPathMatcher(include, nil, "")
+			if err != nil {
+				log.Fatalf("Invalid patterns: %v", err)
+			}
+		}
+		switch reorganizeOptions.Operation {
+		case gitlabapi.ReorganizeArchive:
+			days := prompt(reader, "Archive projects without activity for N days: ")
+			reorganizeOptions.InactiveDays, err = strconv.Atoi(days)
+			if err != nil || reorganizeOptions.InactiveDays < 1 {
+				log.Fatalf("Invalid number of days: %s", days)
+			}
+		case gitlabapi.ReorganizeUnarchive:
+		case gitlabapi.ReorganizeTransfer:
+			if reorganizeOptions.Matcher == nil {
+				log.Fatalf("Transfer needs project path patterns")
+			}
+			reorganizeOptions.Namespace = prompt(reader, "Enter the target namespace path: ")
+		case gitlabapi.ReorganizeRename:
+			renamesPath := prompt(reader, "Enter the rename mapping file: ")
+			reorganizeOptions.Renames, err = gitlabapi.LoadProjectRenames(renamesPath)
+			if err != nil {
+				log.Fatalf("Failed to load rename mapping: %v", err)
+			}
+		default:
+			log.Fatalf("Invalid operation: %s", reorganizeOptions.Operation)
+		}
+		reorganizeOptions.DryRun = promptYesNo(reader, "Dry run (only preview changes)? (y/n): ")
+
+		// The rollback record is always written as CSV, each change before it is made
+		defaultPath := fmt.Sprintf("reorganize-%s-%s.csv", reorganizeOptions.Operation, time.Now().Format("20060102-150405"))
+		reportPath = prompt(reader, "Enter the CSV file for the rollback record (default "+defaultPath+"): ")
+		if reportPath == "" {
+			reportPath = defaultPath
+		}
+		report = gitlabapi.NewReorganizeReport()
+		if err := report.OpenJournal(reportPath); err != nil {
+			log.Fatalf("Failed to create %s: %v", reportPath, err)
+		}
+	}
 
+	if action == "migrate-default-branch" {
+		// Prompt for the old and new default branch and what happens to the old one
+		migration.OldBranch = prompt(reader, "Enter the old default branch (default: current default branch): ")
+		migration.NewBranch = prompt(reader, "Enter the new default branch (default main): ")
+		if migration.NewBranch == "" {
+			migration.NewBranch = "main"
+		}
+		migration.OldAction = prompt(reader, "What to do with the old branch? (keep/protect/delete, default keep): ")
+		switch migration.OldAction {
+		case "":
+			migration.OldAction = gitlabapi.OldBranchKeep
+		case gitlabapi.OldBranchKeep, gitlabapi.OldBranchProtect, gitlabapi.OldBranchDelete:
+		default:
+			log.Fatalf("Invalid choice: %s", migration.OldAction)
+		}
+		migration.DryRun = promptYesNo(reader, "Dry run? (y/n): ")
+		report = gitlabapi.NewMigrationReport()
+	}
+
+	if reportActions[action] {
+		// Prompt for the report output
+		reportFormat = prompt(reader, "Enter report format (table/json/csv, default table): ")
+		if err := gitlabapi.ValidateReportFormat(reportFormat); err != nil {
+			log.Fatalf("Invalid report format: %v", err)
+		}
+		reportPath = prompt(reader, "Enter report file (empty for stdout): ")
 	}
 
 	if action == "trigger-pipeline" {
@@ -131,25 +621,127 @@ func main() {
 			log.Fatalf("Failed to read branch name: %v", err)
 		}
 		triggerBranch = strings.TrimSpace(triggerBranch)
+
+		// Prompt for how the pipeline is started
+		pipelineOptions.Mode = prompt(reader, "Enter pipeline mode (api/trigger/merge-request, default api; merge-request treats the branch as a prefix): ")
+		switch pipelineOptions.Mode {
+		case "", gitlabapi.PipelineAPI, gitlabapi.PipelineTrigger, gitlabapi.PipelineMergeRequest:
+		default:
+			log.Fatalf("Invalid pipeline mode: %s", pipelineOptions.Mode)
+		}
+		if pipelineOptions.Mode == gitlabapi.PipelineTrigger {
+			err = gitlabapi.UseRateLimiter(limiter, func() error {
+				var err error
+				pipelineOptions.UserID, err = gitlabapi.CurrentUserID(client)
+				return err
+			})
+			if err != nil {
+				log.Fatalf("Failed to look up the trigger token owner: %v", err)
+			}
+		}
+
+		// Prompt for the pipeline variables until an empty line is entered; merge request
+		// pipelines do not accept variables
+		for pipelineOptions.Mode != gitlabapi.PipelineMergeRequest {
+			definition := prompt(reader, "Enter pipeline variable (KEY=value, file:KEY=value for file type, empty to finish): ")
+			if definition == "" {
+				break
+			}
+			variable, err := gitlabapi.ParsePipelineVariable(definition)
+			if err != nil {
+				log.Fatalf("Invalid variable: %v", err)
+			}
+			pipelineOptions.Variables = append(pipelineOptions.Variables, variable)
+		}
+
+		// Prompt for waiting on the pipelines to finish
+		waitPipelines = promptYesNo(reader, "Wait for pipelines to finish? (y/n): ")
+		if waitPipelines {
+			pipelineTimeout = 30 * time.Minute
+			if minutes := prompt(reader, "Enter timeout in minutes (default 30): "); minutes != "" {
+				n, err := strconv.Atoi(minutes)
+				if err != nil {
+					log.Fatalf("Invalid timeout: %v", err)
+				}
+				pipelineTimeout = time.Duration(n) * time.Minute
+			}
+		}
+		report = gitlabapi.NewPipelineReport()
 	}
 
 	// Prompt for the reference branch and new branch names if the action is "create-branch"
 	if action == "create-branch" {
 		// Prompt for the reference branch
-		fmt.Print("Enter the reference branch: ")
+		fmt.Print("Enter the reference (branch name, default, tag:<pattern> or sha:<mapping file>): ")
 		refBranch, err = reader.ReadString('\n')
 		if err != nil {
 			log.Fatalf("Failed to read reference branch: %v", err)
 		}
-		refBranch = strings.TrimSpace(refBranch)
+		refSpec, err = gitlabapi.ParseRefSpec(strings.TrimSpace(refBranch))
+		if err != nil {
+			log.Fatalf("Invalid reference: %v", err)
+		}
 
 		// Prompt for the new branch
-		fmt.Print("Enter the new branch: ")
+		fmt.Print("Enter the new branch (template, e.g. release/{{.Version}} or hotfix/{{.Name}}-{{.Date}}): ")
 		newBranch, err = reader.ReadString('\n')
 		if err != nil {
 			log.Fatalf("Failed to read new branch: %v", err)
 		}
 		newBranch = strings.TrimSpace(newBranch)
+
+		// Prompt for the file the version is read from
+		versionFile = prompt(reader, "Enter the version file for {{.Version}} (e.g. VERSION or package.json, optional): ")
+
+		// Prompt for the protection of the new branch
+		protection = promptBranchProtection(reader)
+	}
+
+	// Group variables are managed once on the group instead of on each project
+	if action == "variables" && groupLevel {
+		err = gitlabapi.UseRateLimiter(limiter, func() error {
+			return gitlabapi.ManageGroupVariables(client, groupID, groups[0].FullPath, variablesOperation, variableSpecs, dryRun, report)
+		})
+		if err != nil {
+			log.Fatalf("Failed to manage group variables: %v", err)
+		}
+		if err := gitlabapi.WriteReportFile(reportPath, reportFormat, report); err != nil {
+			log.Fatalf("Failed to write report: %v", err)
+		}
+		return
+	}
+
+	// Projects are reorganised once for the whole group, as transfers change the pages
+	if action == "reorganize" {
+		var changes []*gitlabapi.ProjectReorganization
+		err = gitlabapi.UseRateLimiter(limiter, func() error {
+			changes, err = gitlabapi.PlanReorganization(client, groupID, reorganizeOptions)
+			return err
+		})
+		if err != nil {
+			log.Printf("Failed to plan the reorganisation: %v\n", err)
+			failed = true
+		}
+
+		// Wait for the limiter before every project change
+		for _, change := range changes {
+			err = gitlabapi.UseRateLimiter(limiter, func() error {
+				return gitlabapi.ApplyReorganization(change, reorganizeOptions.Operation, reorganizeOptions.DryRun, report)
+			})
+			if err != nil {
+				log.Printf("Failed to reorganize project %s: %v\n", change.Project.PathWithNamespace, err)
+				failed = true
+				break
+			}
+		}
+		if err := report.Close(); err != nil {
+			log.Fatalf("Failed to write %s: %v", reportPath, err)
+		}
+		fmt.Printf("Rollback record written to %s\n", reportPath)
+		if failed {
+			os.Exit(1)
+		}
+		return
 	}
 
 	// Process projects in chunks of 20
@@ -173,10 +765,105 @@ func main() {
 			case "change-project-rules":
 
 				err = gitlabapi.UseRateLimiter(limiter, func() error {
-					return gitlabapi.ChangeProjectRules(client, project.ID, project.Name, newRegex)
+					return gitlabapi.ChangeProjectRules(client, project.ID, project.Name, pushRules, dryRun)
 				})
 				if err != nil {
-					log.Printf("Failed to create branch and protect for project %s: %v\n", project.Name, err)
+					log.Printf("Failed to change push rules for project %s: %v\n", project.Name, err)
+				}
+			case "reconcile-settings":
+				err = gitlabapi.UseRateLimiter(limiter, func() error {
+					return gitlabapi.ReconcileProjectSettings(client, project.ID, projectSettings, dryRun)
+				})
+				if err != nil {
+					log.Printf("Failed to reconcile settings for project %s: %v\n", project.Name, err)
+				}
+			case "approvals":
+				err = gitlabapi.UseRateLimiter(limiter, func() error {
+					return gitlabapi.ReconcileApprovals(client, project, approvalConfig, dryRun)
+				})
+				if err != nil {
+					log.Printf("Failed to reconcile approvals for project %s: %v\n", project.Name, err)
+				}
+			case "check":
+				err = gitlabapi.UseRateLimiter(limiter, func() error {
+					results, err := gitlabapi.CheckCompliance(client, project, policy)
+					if err != nil {
+						return err
+					}
+					matrix.Add(results)
+					return nil
+				})
+				if err != nil {
+					log.Printf("Failed to check project %s: %v\n", project.Name, err)
+					failed = true
+				}
+			case "pipeline-health":
+				err = gitlabapi.UseRateLimiter(limiter, func() error {
+					return gitlabapi.PipelineHealth(client, project, healthRef, healthDepth, report)
+				})
+				if err != nil {
+					log.Printf("Failed to get pipeline health for project %s: %v\n", project.Name, err)
+				}
+			case "retry-pipelines", "cancel-pipelines":
+				operation := gitlabapi.RetryPipelines
+				if action == "cancel-pipelines" {
+					operation = gitlabapi.CancelPipelines
+				}
+				var pipelines []*gitlab.PipelineInfo
+				err = gitlabapi.UseRateLimiter(limiter, func() error {
+					pipelines, err = gitlabapi.FindPipelines(client, project, operation, pipelineFilter)
+					return err
+				})
+				// Wait for the limiter before every retry or cancel request
+				for _, pipeline := range pipelines {
+					err = gitlabapi.UseRateLimiter(limiter, func() error {
+						return gitlabapi.RetryOrCancelPipeline(client, project, pipeline, operation, dryRun, report)
+					})
+					if err != nil {
+						break
+					}
+				}
+				if err != nil {
+					log.Printf("Failed to %s pipelines for project %s: %v\n", operation, project.Name, err)
+					failed = true
+				}
+			case "variables":
+				err = gitlabapi.UseRateLimiter(limiter, func() error {
+					return gitlabapi.ManageProjectVariables(client, project, variablesOperation, variableSpecs, dryRun, report)
+				})
+				if err != nil {
+					log.Printf("Failed to manage variables for project %s: %v\n", project.Name, err)
+				}
+			case "sync-labels":
+				err = gitlabapi.UseRateLimiter(limiter, func() error {
+					return gitlabapi.SyncLabels(client, project, labelOptions, report)
+				})
+				if err != nil {
+					log.Printf("Failed to sync labels for project %s: %v\n", project.Name, err)
+				}
+			case "members":
+				err = gitlabapi.UseRateLimiter(limiter, func() error {
+					return gitlabapi.ManageMembers(client, project, memberChange, dryRun, report)
+				})
+				if err != nil {
+					log.Printf("Failed to manage members for project %s: %v\n", project.Name, err)
+				}
+			case "access-review":
+				err = gitlabapi.UseRateLimiter(limiter, func() error {
+					return reviewer.Review(project, report)
+				})
+				if err != nil {
+					log.Printf("Failed to review access for project %s: %v\n", project.Name, err)
+				}
+			case "webhooks":
+				err = gitlabapi.UseRateLimiter(limiter, func() error {
+					if webhookOperation == gitlabapi.WebhooksAudit {
+						return gitlabapi.AuditWebhooks(client, project, allowedHosts, report)
+					}
+					return gitlabapi.ManageWebhook(client, project, webhookOperation, webhook, dryRun, report)
+				})
+				if err != nil {
+					log.Printf("Failed to manage webhooks for project %s: %v\n", project.Name, err)
 				}
 			case "close-mr":
 				err = gitlabapi.UseRateLimiter(limiter, func() error {
@@ -187,36 +874,100 @@ func main() {
 				}
 			case "create-mr":
 				err = gitlabapi.UseRateLimiter(limiter, func() error {
-					return gitlabapi.CreateMerge(client, project.ID, sourceBranch, targetBranch)
+					return gitlabapi.CreateMerge(client, project.ID, sourceBranch, targetBranch, describeMR)
 				})
 				if err != nil {
 					log.Printf("Failed to create branch and protect for project %s: %v\n", project.Name, err)
 				}
 			case "trigger-pipeline":
 				err = gitlabapi.UseRateLimiter(limiter, func() error {
-					return gitlabapi.TriggerPipeline(client, project.ID, triggerBranch)
+					results, err := gitlabapi.TriggerPipeline(client, project, triggerBranch, pipelineOptions)
+					pipelineResults = append(pipelineResults, results...)
+					return err
 				})
 				if err != nil {
-					log.Printf("Failed to create branch and protect for project %s: %v\n", project.Name, err)
+					log.Printf("Failed to trigger pipeline for project %s: %v\n", project.Name, err)
+					failed = true
 				}
 			case "create-branch":
 				err = gitlabapi.UseRateLimiter(limiter, func() error {
-					return gitlabapi.CreateBranchAndProtect(client, project.ID, refBranch, newBranch)
+					ref, err := gitlabapi.ResolveRef(client, project, refSpec)
+					if err != nil {
+						return err
+					}
+					branchName, err := gitlabapi.RenderBranchName(client, project, ref, newBranch, versionFile)
+					if err != nil {
+						return err
+					}
+					return gitlabapi.CreateBranchAndProtect(client, project.ID, ref, branchName, protection)
 				})
 				if err != nil {
 					log.Printf("Failed to create branch and protect for project %s: %v\n", project.Name, err)
 				}
+			case "protect-branches", "unprotect-branches", "protect-tags", "unprotect-tags":
+				for _, pattern := range protectPatterns {
+					pattern := pattern
+					err = gitlabapi.UseRateLimiter(limiter, func() error {
+						switch action {
+						case "protect-branches":
+							return gitlabapi.EnsureBranchProtection(client, project.ID, pattern, protection)
+						case "unprotect-branches":
+							return gitlabapi.UnprotectBranch(client, project.ID, pattern)
+						case "protect-tags":
+							return gitlabapi.EnsureTagProtection(client, project.ID, pattern, tagCreateLevel)
+						}
+						return gitlabapi.UnprotectTag(client, project.ID, pattern)
+					})
+					if err != nil {
+						log.Printf("Failed to update protection of %s for project %s: %v\n", pattern, project.Name, err)
+					}
+				}
+			case "protection-report":
+				err = gitlabapi.UseRateLimiter(limiter, func() error {
+					return gitlabapi.ProtectionState(client, project, report)
+				})
+				if err != nil {
+					log.Printf("Failed to report protection for project %s: %v\n", project.Name, err)
+				}
+			case "delete-stale-branches":
+				err = gitlabapi.UseRateLimiter(limiter, func() error {
+					return gitlabapi.DeleteStaleBranches(client, project, staleOptions, report)
+				})
+				if err != nil {
+					log.Printf("Failed to delete stale branches for project %s: %v\n", project.Name, err)
+				}
+			case "create-release":
+				err = gitlabapi.UseRateLimiter(limiter, func() error {
+					return gitlabapi.CreateRelease(client, project, releaseOptions, report)
+				})
+				if err != nil {
+					log.Printf("Failed to create release for project %s: %v\n", project.Name, err)
+				}
+			case "migrate-default-branch":
+				err = gitlabapi.UseRateLimiter(limiter, func() error {
+					return gitlabapi.MigrateDefaultBranch(client, project, migration, report)
+				})
+				if err != nil {
+					log.Printf("Failed to migrate default branch for project %s: %v\n", project.Name, err)
+				}
 			case "create-gitignore":
 				err = gitlabapi.UseRateLimiter(limiter, func() error {
 					branchName := "feature/add-gitignore"
 					ignorePath := "assets/gitignore"
-					return gitlabapi.CreateBranchAndIgnore(client, project.ID, branchName, ignorePath)
+					return gitlabapi.CreateBranchAndIgnore(client, project, branchName, ignorePath)
 				})
 				if err != nil {
 					log.Printf("Failed to create branch and .gitignore for project %s: %v\n", project.Name, err)
 					// Continue to the next project after an error
 					continue
 				}
+			case "sync-files":
+				err = gitlabapi.UseRateLimiter(limiter, func() error {
+					return gitlabapi.SyncFiles(client, project, syncConfig)
+				})
+				if err != nil {
+					log.Printf("Failed to sync files for project %s: %v\n", project.Name, err)
+				}
 			case "accept-merge-request":
 				err = gitlabapi.UseRateLimiter(limiter, func() error {
 					return gitlabapi.AcceptMergeRequests(client, project.ID, branchPrefix)
@@ -228,13 +979,34 @@ func main() {
 				}
 			case "delete-car-files":
 				err = gitlabapi.UseRateLimiter(limiter, func() error {
-					return gitlabapi.DeleteCarFilesAndCreateMergeRequest(client, project.ID)
+					return gitlabapi.DeleteCarFilesAndCreateMergeRequest(client, project)
 				})
 				if err != nil {
 					log.Printf("Failed to delete .car files and create merge requests for project %s: %v\n", project.Name, err)
 					// Continue to the next project after an error
 					continue
 				}
+			case "purge-files":
+				err = gitlabapi.UseRateLimiter(limiter, func() error {
+					return gitlabapi.PurgeFiles(client, project, purgeOptions)
+				})
+				if err != nil {
+					log.Printf("Failed to purge files for project %s: %v\n", project.Name, err)
+				}
+			case "replace-content":
+				err = gitlabapi.UseRateLimiter(limiter, func() error {
+					return gitlabapi.ReplaceInFiles(client, project, replaceOptions)
+				})
+				if err != nil {
+					log.Printf("Failed to replace content for project %s: %v\n", project.Name, err)
+				}
+			case "audit":
+				err = gitlabapi.UseRateLimiter(limiter, func() error {
+					return gitlabapi.Audit(client, project, auditOptions, report)
+				})
+				if err != nil {
+					log.Printf("Failed to audit project %s: %v\n", project.Name, err)
+				}
 			default:
 				log.Fatalf("Invalid action: %s", action)
 			}
@@ -248,4 +1020,118 @@ func main() {
 		// Move to the next page
 		page++
 	}
+
+	// Wait for all triggered pipelines together, then report them
+	if action == "trigger-pipeline" {
+		if waitPipelines {
+			if err := gitlabapi.WaitForPipelines(client, pipelineResults, pipelineTimeout); err != nil {
+				log.Printf("Failed to wait for pipelines: %v\n", err)
+				failed = true
+			}
+		}
+		for _, result := range pipelineResults {
+			gitlabapi.AddPipelineResult(report, result)
+			if result.Failed() || result.Blocked() {
+				failed = true
+			}
+		}
+	}
+
+	// Close the CSV files written while the action ran
+	if action == "delete-stale-branches" {
+		if err := report.Close(); err != nil {
+			log.Fatalf("Failed to write %s: %v", reportPath, err)
+		}
+	}
+
+	// Write the report collected by the action
+	if reportActions[action] {
+		if err := gitlabapi.WriteReportFile(reportPath, reportFormat, report); err != nil {
+			log.Fatalf("Failed to write report: %v", err)
+		}
+	}
+
+	if action == "check" {
+		if err := gitlabapi.WriteComplianceFiles(matrix, reportFormat, reportPath, junitPath); err != nil {
+			log.Fatalf("Failed to write compliance report: %v", err)
+		}
+		if n := matrix.Failed(); n > 0 {
+			log.Printf("%d policy rule(s) failed", n)
+			failed = true
+		}
+	}
+
+	// Exit non-zero so CI jobs fail when a check did not pass
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// prompt prints the label and returns the trimmed line read from the reader.
+func prompt(reader *bufio.Reader, label string) string {
+	fmt.Print(label)
+	value, err := reader.ReadString('\n')
+	if err != nil {
+		log.Fatalf("Failed to read input: %v", err)
+	}
+	return strings.TrimSpace(value)
+}
+
+// promptYesNo prompts for a yes/no answer and returns true for "y" or "yes".
+func promptYesNo(reader *bufio.Reader, label string) bool {
+	answer := strings.ToLower(prompt(reader, label))
+	return answer == "y" || answer == "yes"
+}
+
+// promptAccessLevel prompts for an access level name, falling back to def when empty.
+func promptAccessLevel(reader *bufio.Reader, label string, def gitlab.AccessLevelValue) gitlab.AccessLevelValue {
+	name := prompt(reader, label)
+	if name == "" {
+		return def
+	}
+	level, err := gitlabapi.ParseAccessLevel(name)
+	if err != nil {
+		log.Fatalf("Invalid access level: %v", err)
+	}
+	return level
+}
+
+// promptIDs prompts for a comma-separated list of numeric IDs.
+func promptIDs(reader *bufio.Reader, label string) []int {
+	var ids []int
+	for _, field := range strings.Split(prompt(reader, label), ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		id, err := strconv.Atoi(field)
+		if err != nil {
+			log.Fatalf("Invalid ID %q: %v", field, err)
+		}
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// promptBranchProtection prompts for the protection settings of a branch.
+func promptBranchProtection(reader *bufio.Reader) gitlabapi.BranchProtection {
+	protection := gitlabapi.DefaultBranchProtection()
+	protection.Protect = promptYesNo(reader, "Protect the branch? (y/n): ")
+	if !protection.Protect {
+		return protection
+	}
+	return promptProtectionSettings(reader, protection)
+}
+
+// promptProtectionSettings prompts for the access levels and options of a branch
+// protection, using the values of protection as defaults.
+func promptProtectionSettings(reader *bufio.Reader, protection gitlabapi.BranchProtection) gitlabapi.BranchProtection {
+	protection.PushAccessLevel = promptAccessLevel(reader, "Push access level (no/developer/maintainer/admin, default no): ", protection.PushAccessLevel)
+	protection.MergeAccessLevel = promptAccessLevel(reader, "Merge access level (no/developer/maintainer/admin, default maintainer): ", protection.MergeAccessLevel)
+	protection.UnprotectAccessLevel = promptAccessLevel(reader, "Unprotect access level (developer/maintainer/admin, default maintainer): ", protection.UnprotectAccessLevel)
+	protection.AllowedUserIDs = promptIDs(reader, "User IDs allowed to push and merge (comma-separated, optional): ")
+	protection.AllowedGroupIDs = promptIDs(reader, "Group IDs allowed to push and merge (comma-separated, optional): ")
+	protection.AllowForcePush = promptYesNo(reader, "Allow force push? (y/n): ")
+	protection.CodeOwnerApprovalRequired = promptYesNo(reader, "Require code owner approval? (y/n): ")
+	return protection
 }